@@ -0,0 +1,142 @@
+package drilldown
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ReadOnlyActions exposes only the list and retrieve routes
+var ReadOnlyActions = []Action{ActionList, ActionRetrieve}
+
+type route struct {
+	method  string
+	path    string
+	action  Action
+	handler gin.HandlerFunc
+	// model handled by the route, used to document the generated actions
+	model reflect.Type
+}
+
+// actionEnabled tells if the action is exposed by the resource
+func (config *ApiConfig) actionEnabled(action Action) bool {
+	if len(config.Actions) == 0 {
+		return true
+	}
+
+	for _, a := range config.Actions {
+		if a == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mountRoutes registers the routes of the enabled actions, the methods of the
+// disabled ones answer 405 on the paths that still have some enabled action.
+// It returns the routes registered
+func mountRoutes(r gin.IRoutes, config *ApiConfig, name string, routes []route) []route {
+	mounted := []route{}
+	allowed := map[string][]string{}
+	for _, rt := range routes {
+		if config.actionEnabled(rt.action) {
+			allowed[rt.path] = append(allowed[rt.path], rt.method)
+		}
+	}
+
+	for _, rt := range routes {
+		if config.actionEnabled(rt.action) {
+			r.Handle(rt.method, rt.path, withTenancy(config, withCache(config, name, rt))...)
+			mounted = append(mounted, rt)
+		} else if methods := allowed[rt.path]; len(methods) > 0 {
+			r.Handle(rt.method, rt.path, methodNotAllowed(config, methods))
+		}
+	}
+
+	return mounted
+}
+
+// handle registers a route that is not subject to the actions of the resource
+func (res *Resource[M]) handle(rt route) {
+	res.router.Handle(rt.method, rt.path, withTenancy(res.config, withCache(res.config, res.name, rt))...)
+	res.routes = append(res.routes, rt)
+}
+
+func methodNotAllowed(config *ApiConfig, methods []string) gin.HandlerFunc {
+	allow := strings.Join(methods, ", ")
+
+	return func(c *gin.Context) {
+		// set even when empty, c.Header would drop it
+		c.Writer.Header().Set("Allow", allow)
+		abortWithError(c, config, NewHTTPError(http.StatusMethodNotAllowed, "Method not allowed"))
+	}
+}
+
+// CollectionAction registers a custom route under the collection path, ex: GET /authors/stats.
+// The handler receives a query on the model with the scopes and the permission filter
+// of the resource already applied, write methods run inside a transaction.
+// It writes the response itself, a returned error is sent to the client instead
+func (res *Resource[M]) CollectionAction(method string, name string, handler func(c *gin.Context, db *gorm.DB) error) *Resource[M] {
+	action := Action(name)
+
+	res.handle(route{method, fmt.Sprintf("%v/%v", res.path, name), action, func(c *gin.Context) {
+		if err := checkPermission(c, res.config, action); err != nil {
+			abortWithError(c, res.config, err)
+			return
+		}
+
+		err := runAction(c, res.config, method, func(tx *gorm.DB) error {
+			q := tx.Model(new(M))
+			if scopes := res.config.scopes(method); len(scopes) > 0 {
+				q = q.Scopes(scopes...)
+			}
+
+			return handler(c, filterRows(c, res.config, q))
+		})
+
+		if err != nil {
+			abortWithError(c, res.config, err)
+		}
+	}, nil})
+
+	return res
+}
+
+// ItemAction registers a custom route under the item path, ex: POST /books/:id/publish.
+// The item is looked up as in the generated routes, with the same scopes, permissions
+// and errors, write methods run inside a transaction.
+// It writes the response itself, a returned error is sent to the client instead
+func (res *Resource[M]) ItemAction(method string, name string, handler func(c *gin.Context, tx *gorm.DB, item *M) error) *Resource[M] {
+	action := Action(name)
+
+	res.handle(route{method, fmt.Sprintf("%v/%v", res.pathItem, name), action, func(c *gin.Context) {
+		err, item, _, _ := getItem[M](c, res.config, method, action)
+		if err != nil {
+			return
+		}
+
+		err = runAction(c, res.config, method, func(tx *gorm.DB) error {
+			return handler(c, tx, item)
+		})
+
+		if err != nil {
+			abortWithError(c, res.config, err)
+		}
+	}, nil})
+
+	return res
+}
+
+// runAction runs fn inside a transaction unless the method is a read
+func runAction(c *gin.Context, config *ApiConfig, method string, fn func(tx *gorm.DB) error) error {
+	if method == http.MethodGet || method == http.MethodHead {
+		return fn(database(c, config).WithContext(c))
+	}
+
+	return database(c, config).WithContext(c).Transaction(fn)
+}
@@ -0,0 +1,211 @@
+package drilldown
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// RegisterManyToMany exposes the many to many association of the resource model
+// as relationship routes on its items, ex:
+//
+//	books := RegisterModel(router, Book{}, "books", nil)
+//	tags := RegisterModel(router, Tag{}, "tags", nil)
+//	RegisterManyToMany(books, tags, "Tags")
+//
+// creates GET /books/:id/tags listing the tags of the book, POST and DELETE
+// /books/:id/tags/:tag_id attaching and detaching a tag and PUT /books/:id/tags
+// replacing the tags with the ones in the body, a JSON array of tag ids.
+// Both sides are looked up with their own scopes and permissions. The list is disabled
+// with the retrieve action and changing the relationship counts as updating the item:
+// the routes are disabled with the update action, check its If-Match and are audited
+// and published as updates. DB must be set before calling it
+func RegisterManyToMany[M any, R any](res *Resource[M], related *Resource[R], association string) {
+	stmt := &gorm.Statement{DB: DB}
+	if err := stmt.Parse(new(M)); err != nil {
+		panic(fmt.Sprintf("drilldown: invalid model: %v", err))
+	}
+
+	rel, ok := stmt.Schema.Relationships.Relations[association]
+	if !ok || rel.Type != schema.Many2Many {
+		panic(fmt.Sprintf("drilldown: %v is not a many to many association of %v", association, stmt.Schema.Name))
+	}
+
+	relatedLookup := related.lookupColumn()
+	relatedParam := fmt.Sprintf("%v_%v", removePlural(related.name), relatedLookup)
+	path := fmt.Sprintf("%v/%v", res.pathItem, related.name)
+	pathItem := fmt.Sprintf("%v/:%v", path, relatedParam)
+
+	// relatedItems looks up the related items by their lookup field,
+	// failing when any of them doesn't exist or isn't visible to the request
+	relatedItems := func(c *gin.Context, tx *gorm.DB, ids []interface{}) ([]R, error) {
+		unique := map[string]bool{}
+		for _, id := range ids {
+			unique[fmt.Sprint(id)] = true
+		}
+
+		var items []R
+		q := tx.Model(new(R))
+		if len(related.config.ScopesFind) > 0 {
+			q = q.Scopes(related.config.ScopesFind...)
+		}
+		q = filterRows(c, related.config, q)
+		if err := q.Where(fmt.Sprintf("%v IN ?", relatedLookup), ids).Find(&items).Error; err != nil {
+			return nil, err
+		}
+
+		if len(items) != len(unique) {
+			return nil, NewHTTPError(http.StatusNotFound, fmt.Sprintf("Related %v not found!", related.name))
+		}
+
+		return items, nil
+	}
+
+	// loadRelated sets the related items on the item, so they are part of its audit entries and events
+	loadRelated := func(c *gin.Context, tx *gorm.DB, item *M) error {
+		if res.config.Audit == nil && res.config.Outbox == nil {
+			return nil
+		}
+
+		items := reflect.New(rel.Field.FieldType)
+		if err := tx.Model(item).Association(association).Find(items.Interface()); err != nil {
+			return err
+		}
+
+		return rel.Field.Set(c, reflect.ValueOf(item).Elem(), items.Elem().Interface())
+	}
+
+	// writeHandler changes the relationship as an update of the item, with its preconditions,
+	// audit entry and event
+	writeHandler := func(write func(c *gin.Context, tx *gorm.DB, assoc *gorm.Association) error) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			err, item, _, _ := getItem[M](c, res.config, http.MethodPut, ActionUpdate)
+			if err != nil {
+				return
+			}
+
+			if err := res.checkIfMatch(c, item); err != nil {
+				abortWithError(c, res.config, err)
+				return
+			}
+
+			err = database(c, res.config).WithContext(c).Transaction(func(tx *gorm.DB) error {
+				if err := res.lockIfMatch(c, tx, http.MethodPut, item); err != nil {
+					return err
+				}
+
+				if err := loadRelated(c, tx, item); err != nil {
+					return err
+				}
+
+				before, err := res.snapshot(item)
+				if err != nil {
+					return err
+				}
+
+				if err := write(c, tx, tx.Model(item).Association(association)); err != nil {
+					return err
+				}
+
+				if err := loadRelated(c, tx, item); err != nil {
+					return err
+				}
+
+				if err := res.audit(c, tx, ActionUpdate, before, item); err != nil {
+					return err
+				}
+
+				return res.enqueue(c, tx, ActionUpdate, item)
+			})
+
+			if err != nil {
+				abortWithError(c, res.config, err)
+				return
+			}
+
+			c.JSON(http.StatusNoContent, nil)
+		}
+	}
+
+	list := route{http.MethodGet, path, Action(related.name), func(c *gin.Context) {
+		err, item, _, _ := getItem[M](c, res.config, http.MethodGet, ActionRetrieve)
+		if err != nil {
+			return
+		}
+
+		var items []R
+		q := database(c, res.config).WithContext(c)
+		if len(related.config.ScopesFind) > 0 {
+			q = q.Scopes(related.config.ScopesFind...)
+		}
+		q = filterRows(c, related.config, q)
+		if err := q.Model(item).Association(association).Find(&items); err != nil {
+			abortWithError(c, res.config, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": items})
+	}, nil}
+
+	writes := []route{}
+	writes = append(writes, route{http.MethodPost, pathItem, ActionUpdate, writeHandler(func(c *gin.Context, tx *gorm.DB, assoc *gorm.Association) error {
+		items, err := relatedItems(c, tx, []interface{}{c.Param(relatedParam)})
+		if err != nil {
+			return err
+		}
+
+		return assoc.Append(&items)
+	}), nil})
+
+	writes = append(writes, route{http.MethodDelete, pathItem, ActionUpdate, writeHandler(func(c *gin.Context, tx *gorm.DB, assoc *gorm.Association) error {
+		items, err := relatedItems(c, tx, []interface{}{c.Param(relatedParam)})
+		if err != nil {
+			return err
+		}
+
+		return assoc.Delete(&items)
+	}), nil})
+
+	writes = append(writes, route{http.MethodPut, path, ActionUpdate, writeHandler(func(c *gin.Context, tx *gorm.DB, assoc *gorm.Association) error {
+		var ids []interface{}
+		if err := c.ShouldBindJSON(&ids); err != nil {
+			return NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Expected a list of %v ids: %v", related.name, err))
+		}
+
+		if len(ids) == 0 {
+			return assoc.Clear()
+		}
+
+		items, err := relatedItems(c, tx, ids)
+		if err != nil {
+			return err
+		}
+
+		return assoc.Replace(&items)
+	}), nil})
+
+	// the routes are enabled by the retrieve and update actions of the resource
+	retrieve, update := res.config.actionEnabled(ActionRetrieve), res.config.actionEnabled(ActionUpdate)
+	if retrieve {
+		res.handle(list)
+	} else if update {
+		res.router.Handle(http.MethodGet, path, methodNotAllowed(res.config, []string{http.MethodPut}))
+	}
+
+	if update {
+		for _, rt := range writes {
+			res.handle(rt)
+		}
+		return
+	}
+
+	if retrieve {
+		res.router.Handle(http.MethodPut, path, methodNotAllowed(res.config, []string{http.MethodGet}))
+	}
+	res.router.Handle(http.MethodPost, pathItem, methodNotAllowed(res.config, nil))
+	res.router.Handle(http.MethodDelete, pathItem, methodNotAllowed(res.config, nil))
+}
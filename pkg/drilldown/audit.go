@@ -0,0 +1,253 @@
+package drilldown
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const actionHistory Action = "history"
+
+// Audit records the creates, updates and deletes of the generated routes in the audit table,
+// in the same transaction as the write, and exposes the entries of each item on
+// GET /<resource>/:id/history, checked as the history action of the Permission
+type Audit struct {
+	// Actor returns who made the change, ex: the user set in the context by the authentication
+	Actor func(c *gin.Context) string
+	// RequestIDHeader is the header with the ID of the request, X-Request-ID by default
+	RequestIDHeader string
+	// Table of the entries, audit_entries by default, see AuditEntry
+	Table string
+}
+
+// AuditEntry is a change of an item recorded by the Audit, migrate it with
+// DB.AutoMigrate(&drilldown.AuditEntry{}), or DB.Table(table).AutoMigrate for another table
+type AuditEntry struct {
+	ID        uint64 `json:"id"`
+	Resource  string `json:"resource" gorm:"size:191;index:idx_audit_item"`
+	ItemID    string `json:"item_id" gorm:"size:191;index:idx_audit_item"`
+	Action    Action `json:"action" gorm:"size:32"`
+	Actor     string `json:"actor" gorm:"size:191"`
+	RequestID string `json:"request_id" gorm:"size:191"`
+	// Tenant of the request, with Tenancy
+	Tenant string `json:"-" gorm:"size:191"`
+	// Changes maps the JSON names of the changed fields to their values before and after the change
+	Changes   json.RawMessage `json:"changes" gorm:"type:text"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func (a *Audit) table() string {
+	if a.Table == "" {
+		return "audit_entries"
+	}
+
+	return a.Table
+}
+
+// snapshot returns the item as serialized to JSON, nil without auditing
+func (res *Resource[M]) snapshot(item *M) (map[string]interface{}, error) {
+	if res.config.Audit == nil || item == nil {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+	return values, json.Unmarshal(encoded, &values)
+}
+
+// changes returns the fields that differ between the snapshots, with both values
+func changes(before map[string]interface{}, after map[string]interface{}) map[string]gin.H {
+	diff := map[string]gin.H{}
+	for _, values := range []map[string]interface{}{before, after} {
+		for k := range values {
+			if _, ok := diff[k]; !ok && !reflect.DeepEqual(before[k], after[k]) {
+				diff[k] = gin.H{"before": before[k], "after": after[k]}
+			}
+		}
+	}
+
+	return diff
+}
+
+// primaryKey returns the values of the primary key of the item, comma separated
+func (res *Resource[M]) primaryKey(c *gin.Context, item *M) (string, error) {
+	s, err := res.schema()
+	if err != nil {
+		return "", err
+	}
+
+	values := []string{}
+	for _, f := range s.PrimaryFields {
+		value, _ := f.ValueOf(c, reflect.ValueOf(item))
+		values = append(values, fmt.Sprint(reflect.Indirect(reflect.ValueOf(value)).Interface()))
+	}
+
+	return strings.Join(values, ","), nil
+}
+
+// audit records the change of the item, from the snapshot before it, in the transaction of the write
+func (res *Resource[M]) audit(c *gin.Context, tx *gorm.DB, action Action, before map[string]interface{}, item *M) error {
+	config := res.config.Audit
+	if config == nil {
+		return nil
+	}
+
+	var after map[string]interface{}
+	if action != ActionDelete {
+		var err error
+		if after, err = res.snapshot(item); err != nil {
+			return err
+		}
+	}
+
+	key, err := res.primaryKey(c, item)
+	if err != nil {
+		return err
+	}
+
+	diff, err := json.Marshal(changes(before, after))
+	if err != nil {
+		return err
+	}
+
+	header := config.RequestIDHeader
+	if header == "" {
+		header = "X-Request-ID"
+	}
+
+	entry := AuditEntry{
+		Resource:  res.name,
+		ItemID:    key,
+		Action:    action,
+		RequestID: c.GetHeader(header),
+		Changes:   diff,
+	}
+	if config.Actor != nil {
+		entry.Actor = config.Actor(c)
+	}
+	if t, err := tenantOf(c, res.config); err != nil {
+		return err
+	} else if t != nil {
+		entry.Tenant = fmt.Sprint(t.tenant)
+	}
+
+	return tx.Session(&gorm.Session{NewDB: true}).Table(config.table()).Create(&entry).Error
+}
+
+// history lists the audit entries of the item, oldest first. The entries of the deleted
+// items are found by the primary key in the path, limited to the tenant of the request
+func (res *Resource[M]) history(c *gin.Context) {
+	if err := checkPermission(c, res.config, actionHistory); err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	key, err := res.historyKey(c)
+	if err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	entry := AuditEntry{Resource: res.name, ItemID: key}
+	if t, err := tenantOf(c, res.config); err != nil {
+		abortWithError(c, res.config, err)
+		return
+	} else if t != nil {
+		entry.Tenant = fmt.Sprint(t.tenant)
+	}
+
+	entries := []AuditEntry{}
+	err = database(c, res.config).WithContext(c).Table(res.config.Audit.table()).
+		Where(&entry).
+		Order("id").
+		Find(&entries).Error
+	if err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": entries, "errors": []string{}})
+}
+
+// historyKey returns the primary key of the item of the path, read from the path when
+// the item no longer exists and the resource has neither scopes nor a Permission.
+// The items hidden from the request by the scopes or the permissions are not found
+func (res *Resource[M]) historyKey(c *gin.Context) (string, error) {
+	s, err := res.schema()
+	if err != nil {
+		return "", err
+	}
+
+	conditions, _, _, err := lookupConditions(c, s, res.config)
+	if err != nil {
+		return "", err
+	}
+
+	for _, condition := range conditions {
+		var item M
+		q := database(c, res.config).WithContext(c)
+		if scopes := res.config.scopes(http.MethodGet); len(scopes) > 0 {
+			q = q.Scopes(scopes...)
+		}
+		err := filterRows(c, res.config, q).Where(condition).First(&item).Error
+		if err == nil {
+			if err := checkObjectPermission(c, res.config, actionHistory, &item); err != nil {
+				return "", err
+			}
+			return res.primaryKey(c, &item)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", err
+		}
+
+		var count int64
+		if err := database(c, res.config).WithContext(c).Model(new(M)).Where(condition).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count > 0 {
+			return "", errNotFound
+		}
+	}
+
+	// the deleted item can't be checked against the scopes and the permission anymore
+	if res.config.Permission != nil || len(res.config.scopes(http.MethodGet)) > 0 {
+		return "", errNotFound
+	}
+
+	// the item was deleted, its key is in the path when looked up by the primary key
+	fields := res.config.lookupFields()
+	if len(res.config.LookupKey) == 0 {
+		fields = fields[:1]
+	}
+	if len(fields) != len(s.PrimaryFields) {
+		return "", errNotFound
+	}
+
+	params := lookupParams(s, res.config)
+	values := []string{}
+	for i, name := range fields {
+		f := s.LookUpField(name)
+		if f == nil || f != s.PrimaryFields[i] {
+			return "", errNotFound
+		}
+
+		value, err := parseKey(f.FieldType, c.Param(params[i]))
+		if err != nil {
+			return "", errNotFound
+		}
+		values = append(values, fmt.Sprint(value))
+	}
+
+	return strings.Join(values, ","), nil
+}
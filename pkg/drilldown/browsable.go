@@ -0,0 +1,189 @@
+package drilldown
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iancoleman/strcase"
+)
+
+var browsableTemplate = template.Must(template.ParseFS(templatesFS, "templates/browsable.html"))
+
+// browsablePage is the data of the browsable API template
+type browsablePage struct {
+	Title      string
+	Collection string
+	List       bool
+	Columns    []string
+	Rows       []browsableRow
+	Filters    []browsableFilter
+	Form       []browsableField
+	Query      url.Values
+	Prev       string
+	Next       string
+	JSON       string
+}
+
+type browsableRow struct {
+	Link   string
+	Values []string
+}
+
+type browsableFilter struct {
+	Name      string
+	Operators []string
+}
+
+type browsableField struct {
+	Name     string
+	Label    string
+	Input    string
+	Required bool
+	Value    string
+}
+
+// renderBrowsable renders the response as an HTML page with controls to explore the API
+func (res *Resource[M]) renderBrowsable(c *gin.Context, status int, response *Response) {
+	list := response.List
+	encoded, err := json.MarshalIndent(response.Body(), "", "  ")
+	if err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	page := browsablePage{
+		Title:      strcase.ToCamel(res.name),
+		Collection: res.path,
+		List:       list,
+		Query:      c.Request.URL.Query(),
+		JSON:       string(encoded),
+	}
+
+	rows := response.Rows()
+	page.Columns = response.Columns()
+	for _, r := range rows {
+		row := browsableRow{}
+		if key, ok := res.lookupLink(r); ok && list {
+			row.Link = fmt.Sprintf("%v/%v", strings.TrimSuffix(c.Request.URL.Path, "/"), key)
+		}
+		for _, col := range page.Columns {
+			row.Values = append(row.Values, formatValue(valueAt(r, col)))
+		}
+		page.Rows = append(page.Rows, row)
+	}
+
+	for _, f := range modelFields(reflect.TypeOf(res.model)) {
+		input, ok := browsableInput(f.Type)
+		name, hasName := jsonName(f)
+		if !ok || !hasName {
+			continue
+		}
+
+		page.Filters = append(page.Filters, browsableFilter{
+			Name:      strcase.ToSnake(f.Name),
+			Operators: append([]string{""}, filterOperators[browsableFilterType(input)]...),
+		})
+
+		if f.Name == "ID" || f.Name == "CreatedAt" || f.Name == "UpdatedAt" || f.Name == "DeletedAt" {
+			continue
+		}
+
+		field := browsableField{
+			Name:     name,
+			Label:    strings.ReplaceAll(strcase.ToSnake(f.Name), "_", " "),
+			Input:    input,
+			Required: strings.Contains(f.Tag.Get("binding"), "required"),
+		}
+		if !list && len(rows) == 1 && rows[0][name] != nil {
+			field.Value = formatValue(rows[0][name])
+		}
+		page.Form = append(page.Form, field)
+	}
+
+	if list {
+		page.Prev, page.Next = browsablePages(c.Request.URL, len(rows))
+	}
+
+	var html bytes.Buffer
+	if err := browsableTemplate.Execute(&html, page); err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	c.Data(status, "text/html; charset=utf-8", html.Bytes())
+}
+
+// browsablePages returns the links to the previous and next pages of the list
+func browsablePages(u *url.URL, count int) (string, string) {
+	query := u.Query()
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil {
+		if query.Get("offset") == "" {
+			return "", ""
+		}
+		limit = 20
+	}
+	offset, _ := strconv.Atoi(query.Get("offset"))
+
+	link := func(offset int) string {
+		q := u.Query()
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(offset))
+		return fmt.Sprintf("%v?%v", u.Path, q.Encode())
+	}
+
+	prev, next := "", ""
+	if offset > limit {
+		prev = link(offset - limit)
+	} else if offset > 0 {
+		prev = link(0)
+	}
+	if count >= limit {
+		next = link(offset + limit)
+	}
+
+	return prev, next
+}
+
+// browsableInput returns the HTML input type of the field, false when it can't be edited in a form
+func browsableInput(t reflect.Type) (string, bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType, nullTimeType, deletedAtType:
+		return "datetime-local", true
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "checkbox", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number", true
+	case reflect.String:
+		return "text", true
+	}
+
+	return "", false
+}
+
+func browsableFilterType(input string) string {
+	switch input {
+	case "number":
+		return "number"
+	case "checkbox":
+		return "boolean"
+	}
+
+	return "string"
+}
@@ -0,0 +1,288 @@
+package drilldown
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cache stores the responses of the list and item routes, see NewMemoryCache.
+// Adapters to external stores, like Redis, only need to get and set values with a TTL
+type Cache interface {
+	// Get returns the value of the key, false when it is missing or expired
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores the value of the key, without expiration when the TTL is zero
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// CacheConfig caches the responses of the list and item routes of a resource, keyed by
+// their path, query, tenant and the headers of the Vary of the HTTPCache.
+// They are invalidated by the writes through the routes of the resource.
+// The cached responses skip the read hooks and the row filter of the Permission,
+// so the resources with a Permission must set Vary
+type CacheConfig struct {
+	// Store of the responses, a memory LRU shared by the resources when nil
+	Store Cache
+	// TTL of the responses, DefaultCacheTTL when zero
+	TTL time.Duration
+	// Vary returns the part of the key that depends on the request, ex: the user,
+	// required with a Permission
+	Vary func(c *gin.Context) string
+}
+
+// DefaultCacheTTL is the TTL of the responses of the caches without one
+var DefaultCacheTTL = time.Minute
+
+var defaultCache = NewMemoryCache(1024)
+
+func (config *CacheConfig) store() Cache {
+	if config.Store == nil {
+		return defaultCache
+	}
+
+	return config.Store
+}
+
+type cachedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// cacheWriter keeps a copy of the body written to the client
+type cacheWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *cacheWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// generationKey is the key of the current generation of the entries of the resource,
+// the writes start a new one so the previous entries are no longer read
+func generationKey(name string) string {
+	return fmt.Sprintf("drilldown:%v:generation", name)
+}
+
+// cacheKey returns the key of the response to the request, empty when it can't be cached
+func cacheKey(c *gin.Context, config *ApiConfig, name string) string {
+	store := config.Cache.store()
+	generation, ok, err := store.Get(c, generationKey(name))
+	if err != nil {
+		return ""
+	}
+	if !ok {
+		// a generation evicted from the store must not bring back the entries before it
+		if generation, err = newGeneration(c, store, name); err != nil {
+			return ""
+		}
+	}
+
+	tenant := ""
+	if t, err := tenantOf(c, config); err != nil {
+		return ""
+	} else if t != nil {
+		tenant = fmt.Sprint(t.tenant)
+	}
+
+	vary := ""
+	if config.Cache.Vary != nil {
+		vary = config.Cache.Vary(c)
+	}
+
+	headers := []string{c.GetHeader("Accept")}
+	if config.HTTPCache != nil {
+		for _, h := range config.HTTPCache.Vary {
+			headers = append(headers, c.GetHeader(h))
+		}
+	}
+
+	return fmt.Sprintf("drilldown:%v:%s:%q:%q:%v?%v:%q", name, generation, tenant, vary,
+		c.Request.URL.Path, c.Request.URL.Query().Encode(), headers)
+}
+
+// withCache answers the list and item routes from the cache of the resource and invalidates
+// it after the successful writes, the errors of the store are treated as misses
+func withCache(config *ApiConfig, name string, rt route) gin.HandlerFunc {
+	if config == nil || config.Cache == nil {
+		return rt.handler
+	}
+
+	if rt.method != http.MethodGet && rt.method != http.MethodHead {
+		return func(c *gin.Context) {
+			rt.handler(c)
+			if c.Writer.Status() < http.StatusBadRequest {
+				invalidateCache(c, config, name)
+			}
+		}
+	}
+
+	if rt.action != ActionList && rt.action != ActionRetrieve {
+		return rt.handler
+	}
+
+	return func(c *gin.Context) {
+		if stream, _ := strconv.ParseBool(c.Query("stream")); stream {
+			rt.handler(c)
+			return
+		}
+
+		if err := checkPermission(c, config, rt.action); err != nil {
+			abortWithError(c, config, err)
+			return
+		}
+
+		key := cacheKey(c, config, name)
+		if key == "" {
+			rt.handler(c)
+			return
+		}
+
+		store := config.Cache.store()
+		if value, ok, err := store.Get(c, key); err == nil && ok {
+			var cached cachedResponse
+			if err := json.Unmarshal(value, &cached); err == nil {
+				replayResponse(c, &cached)
+				return
+			}
+		}
+
+		w := &cacheWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Header("X-Cache", "MISS")
+		rt.handler(c)
+		c.Writer = w.ResponseWriter
+
+		if c.Writer.Status() != http.StatusOK || c.IsAborted() {
+			return
+		}
+
+		header := c.Writer.Header().Clone()
+		header.Del("X-Cache")
+		value, err := json.Marshal(&cachedResponse{Status: http.StatusOK, Header: header, Body: w.body.Bytes()})
+		if err != nil {
+			return
+		}
+
+		ttl := config.Cache.TTL
+		if ttl == 0 {
+			ttl = DefaultCacheTTL
+		}
+		store.Set(c, key, value, ttl)
+	}
+}
+
+// replayResponse writes the cached response, not modified when the client already has it
+func replayResponse(c *gin.Context, cached *cachedResponse) {
+	for k, v := range cached.Header {
+		c.Writer.Header()[k] = v
+	}
+	c.Header("X-Cache", "HIT")
+
+	if notModified(c, cached.Header) {
+		c.Writer.Header().Del("Content-Type")
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Status(cached.Status)
+	c.Writer.Write(cached.Body)
+}
+
+func newGeneration(ctx context.Context, store Cache, name string) ([]byte, error) {
+	generation := []byte(strconv.FormatInt(time.Now().UnixNano(), 36))
+	return generation, store.Set(ctx, generationKey(name), generation, 0)
+}
+
+func invalidateCache(ctx context.Context, config *ApiConfig, name string) error {
+	_, err := newGeneration(ctx, config.Cache.store(), name)
+	return err
+}
+
+// InvalidateCache discards the cached responses of the resource, for the writes done outside of its routes
+func (res *Resource[M]) InvalidateCache(ctx context.Context) error {
+	if res.config.Cache == nil {
+		return nil
+	}
+
+	return invalidateCache(ctx, res.config, res.name)
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+type memoryCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+// NewMemoryCache returns a Cache keeping up to size entries in memory,
+// discarding the least recently used ones
+func NewMemoryCache(size int) Cache {
+	return &memoryCache{size: size, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+func (m *memoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	entry := e.Value.(*memoryEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		m.order.Remove(e)
+		delete(m.entries, key)
+		return nil, false, nil
+	}
+
+	m.order.MoveToFront(e)
+	return entry.value, true, nil
+}
+
+func (m *memoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := &memoryEntry{key: key, value: value}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+
+	if e, ok := m.entries[key]; ok {
+		e.Value = entry
+		m.order.MoveToFront(e)
+		return nil
+	}
+
+	m.entries[key] = m.order.PushFront(entry)
+	for m.order.Len() > m.size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryEntry).key)
+	}
+
+	return nil
+}
@@ -1,18 +1,17 @@
 package drilldown
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
 	"net/url"
 	"reflect"
-	"strconv"
 	"strings"
+	"sync"
 
-	"github.com/go-playground/validator/v10"
-	"github.com/go-sql-driver/mysql"
-	"github.com/iancoleman/strcase"
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 
 	"github.com/gin-gonic/gin"
 )
@@ -36,14 +35,99 @@ type OrderBy struct {
 
 type ApiConfig struct {
 	LookupField string
-	ScopesFind  []func(db *gorm.DB) *gorm.DB
+	// Fields tried in order when no item has the LookupField, ex: Slug to find
+	// the books both by /books/1 and /books/fight-club
+	LookupAlternatives []string
+	// Fields of a composite key replacing the LookupField, ex: AuthorID and Slug
+	// for /books/:author_id/:slug
+	LookupKey  []string
+	ScopesFind []func(db *gorm.DB) *gorm.DB
+	// Scopes of the lookup and of the statement of the updates and deletes,
+	// ScopesFind when nil, set them empty to not apply any
+	ScopesUpdate []func(db *gorm.DB) *gorm.DB
+	ScopesDelete []func(db *gorm.DB) *gorm.DB
+
+	// Lifecycle hooks called by the generated handlers, see Hook
+	BeforeList   Hook
+	AfterList    Hook
+	BeforeRead   Hook
+	AfterRead    Hook
+	BeforeCreate Hook
+	AfterCreate  Hook
+	BeforeUpdate Hook
+	AfterUpdate  Hook
+	BeforeDelete Hook
+	AfterDelete  Hook
+
+	// Named filters of the list selected by the clients, see Scope
+	Scopes map[string]Scope
+
+	// Fields matched by the search parameter of the list, see SearchConfig
+	Search *SearchConfig
+
+	// Checks run on the item before it is created or updated, see Validator
+	Validators []Validator
+
+	// Integer field incremented by each update, which is rejected when the version
+	// submitted is stale, the one tagged with `drilldown:"version"` when empty
+	VersionField string
+
+	// Records the writes of the generated routes, see Audit
+	Audit *Audit
+
+	// Records the events of the writes of the generated routes, see OutboxConfig and Dispatcher
+	Outbox *OutboxConfig
+
+	// Cache headers of the list and item responses, see HTTPCacheConfig
+	HTTPCache *HTTPCacheConfig
+
+	// Caches the responses of the list and item routes, see CacheConfig
+	Cache *CacheConfig
+
+	// Entity tags of the responses and conditional requests, see ETagConfig
+	ETag *ETagConfig
+
+	// Scopes the resource by the tenant of the request, see Tenancy
+	Tenancy *Tenancy
+
+	// Access control for the resource, see Permission
+	Permission Permission
+
+	// Actions exposed by the resource, all of them when empty
+	Actions []Action
+
+	// Writes the error responses, JSONErrors when nil, see ProblemJSON
+	ErrorFormatter ErrorFormatter
 }
 
 var DB *gorm.DB
 
+// scopes returns the scopes of the requests with the method, the write methods
+// other than DELETE, like the POST of the custom actions, use ScopesUpdate
+func (config *ApiConfig) scopes(method string) []func(db *gorm.DB) *gorm.DB {
+	if config == nil {
+		return nil
+	}
+
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return config.ScopesFind
+	case http.MethodDelete:
+		if config.ScopesDelete != nil {
+			return config.ScopesDelete
+		}
+	default:
+		if config.ScopesUpdate != nil {
+			return config.ScopesUpdate
+		}
+	}
+
+	return config.ScopesFind
+}
+
 func isReservedField(f string) bool {
 
-	if f == "fields" || f == "order" || f == "limit" || f == "offset" {
+	if f == "fields" || f == "order" || f == "limit" || f == "offset" || f == "format" || f == "stream" || f == "search" || f == "scope" {
 		return true
 	}
 
@@ -157,74 +241,70 @@ func prepareOrderBy(orderBy string, c chan []OrderBy) {
 	c <- preparedOrderBy
 }
 
-func getIDParamInt(c *gin.Context, lookupField string) (uint64, error) {
-	idS, ok := c.Params.Get(lookupField)
-	if !ok {
-		return 0, fmt.Errorf("invalid ID (%v)", lookupField)
-	}
-
-	id, err := strconv.Atoi(idS)
-	if err != nil {
-		return 0, fmt.Errorf("invalid ID (%v) %v", lookupField, err)
-	}
-
-	return uint64(id), nil
+func GetItem[M any](c *gin.Context, config *ApiConfig, method string) (error, *M, uint64, *string) {
+	return getItem[M](c, config, method, methodAction(method))
 }
 
-func getIDParamString(c *gin.Context, lookupField string) (*string, error) {
-	id, ok := c.Params.Get(lookupField)
-	if !ok {
-		return nil, fmt.Errorf("invalid ID (%v)", lookupField)
-	}
-
-	return &id, nil
+// getItem looks up the item of the request checking the permissions of the given action.
+// The lookup fields are tried in order, see LookupAlternatives
+func getItem[M any](c *gin.Context, config *ApiConfig, method string, action Action) (error, *M, uint64, *string) {
+	return lookupItem[M](c, config, method, action, nil)
 }
 
-func GetItem[M any](c *gin.Context, config *ApiConfig, method string) (error, *M, uint64, *string) {
+// lookupItem is getItem calling before with the lookup query once the permission is checked,
+// so the conditions it adds restrict the lookup
+func lookupItem[M any](c *gin.Context, config *ApiConfig, method string, action Action, before Hook) (error, *M, uint64, *string) {
 	var item M
 	var idInt uint64
 	var idString *string
-	var err error
 
-	v := reflect.ValueOf(item)
-
-	lookupField := "ID"
-	if config != nil && config.LookupField != "" {
-		lookupField = config.LookupField
+	s, err := parseModel(&item)
+	if err != nil {
+		abortWithError(c, config, err)
+		return err, nil, idInt, idString
 	}
-	lowerLookupField := strings.ToLower(lookupField)
 
-	if v.FieldByName(lookupField).Type().Name() == "uint64" {
-		idInt, err = getIDParamInt(c, lowerLookupField)
-	} else {
-		idString, err = getIDParamString(c, lowerLookupField)
+	conditions, idInt, idString, err := lookupConditions(c, s, config)
+	if err != nil {
+		abortWithError(c, config, err)
+		return err, nil, idInt, idString
 	}
 
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err})
+	if err = checkPermission(c, config, action); err != nil {
+		abortWithError(c, config, err)
 		return err, nil, idInt, idString
 	}
 
-	whereClause := fmt.Sprintf("%s = ?", lowerLookupField)
+	q := database(c, config).WithContext(c).Model(&item)
+	if scopes := config.scopes(method); len(scopes) > 0 {
+		q = q.Scopes(scopes...)
+	}
+	q = filterRows(c, config, q)
 
-	if config != nil && len(config.ScopesFind) > 0 && method == "GET" {
-		DB = DB.WithContext(c).Scopes(config.ScopesFind...)
-	} else {
-		DB = DB.WithContext(c)
+	if err = runHook(before, c, q, nil); err != nil {
+		abortWithError(c, config, err)
+		return err, nil, idInt, idString
 	}
 
-	if idString != nil {
-		if err = DB.Where(whereClause, idString).First(&item).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found!"})
-			return err, nil, idInt, idString
-		}
-	} else {
-		if err = DB.Where(whereClause, idInt).First(&item, idInt).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Record not found!"})
-			return err, nil, idInt, idString
+	for _, condition := range conditions {
+		if err = q.Session(&gorm.Session{}).Where(condition).First(&item).Error; err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+			break
 		}
 	}
 
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		abortWithError(c, config, errNotFound)
+		return err, nil, idInt, idString
+	} else if err != nil {
+		abortWithError(c, config, err)
+		return err, nil, idInt, idString
+	}
+
+	if err = checkObjectPermission(c, config, action, &item); err != nil {
+		abortWithError(c, config, err)
+		return err, nil, idInt, idString
+	}
+
 	return nil, &item, idInt, idString
 }
 
@@ -236,266 +316,94 @@ func removePlural(s string) string {
 	return s
 }
 
-func IsTestRun() bool {
-	f := flag.Lookup("test.v")
-	if f == nil {
-		return false
-	}
-
-	return f.Value.(flag.Getter).Get().(bool)
-}
-
-func RegisterModel[M any](r *gin.Engine, m M, resource string, config *ApiConfig) {
-
-	path := "/" + resource
-	lookupField := "id"
-	if config != nil && config.LookupField != "" {
-		lookupField = strings.ToLower(config.LookupField)
+// modelFields returns the exported fields of the model, including the ones of the embedded structs
+func modelFields(t reflect.Type) []reflect.StructField {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
 	}
-	pathItem := fmt.Sprintf("%v/:%v", path, lookupField)
 
-	r.GET(path, func(c *gin.Context) {
-		qmap := c.Request.URL.Query()
-		var errors []string
-
-		var q *gorm.DB
-		if IsTestRun() {
-			q = DB.Debug().Table(resource)
-		} else {
-			q = DB.Table(resource)
-		}
-
-		if config != nil && len(config.ScopesFind) > 0 {
-			q = q.WithContext(c).Scopes(config.ScopesFind...)
-		}
-
-		selectChan := make(chan Select)
-		condChan := make(chan Condition)
-		orderChan := make(chan []OrderBy)
-
-		fp := qmap.Get("fields")
-		go prepareSelectFields(fp, selectChan)
-		go prepareCondition(resource, qmap, condChan)
-		orderBy := qmap.Get("order")
-		go prepareOrderBy(orderBy, orderChan)
-
-		if len(errors) > 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"errors": errors, "data": []M{}})
-			return
+	fields := []reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("json") == "" {
+			fields = append(fields, modelFields(f.Type)...)
+		} else if f.IsExported() {
+			fields = append(fields, f)
 		}
+	}
 
-		for i := 0; i < 3; i++ {
-			select {
-			case sel := <-selectChan:
-				// JOINS
-				if len(sel.Joins) > 0 {
-					for _, j := range sel.Joins {
-						q = q.Joins(j)
-					}
-				}
-
-				// SELECT
-				if len(sel.Fields) > 0 {
-					preparedFields := []string{}
-					v := reflect.ValueOf(m)
-					for _, f := range sel.Fields {
-						if !strings.Contains(f, ".") && !v.FieldByName(strcase.ToCamel(f)).IsValid() {
-							errors = append(errors, fmt.Sprintf("Invalid field on the fields selector: %v", f))
-						} else {
-							preparedFields = append(preparedFields, f)
-						}
-					}
-
-					if len(errors) > 0 {
-						c.JSON(http.StatusBadRequest, gin.H{"errors": errors, "data": []M{}})
-						return
-					}
-
-					preparedFields = append(preparedFields, fmt.Sprintf("`%v`.id", resource))
-					q = q.Select(preparedFields)
-				}
-			case cond := <-condChan:
-				if len(cond.Joins) > 0 {
-					for _, j := range cond.Joins {
-						q = q.Joins(j)
-					}
-				}
-
-				if len(cond.Values) > 0 {
-					var vs []interface{}
-
-					for _, f := range cond.Fields {
-						v := reflect.ValueOf(m)
-						// Check if field exists in the model
-						if !strings.Contains(f, ".") && !v.FieldByName(strcase.ToCamel(f)).IsValid() {
-							errors = append(errors, fmt.Sprintf("Invalid field on the condition: %v", f))
-						}
-					}
-
-					if len(errors) > 0 {
-						c.JSON(http.StatusBadRequest, gin.H{"errors": errors, "data": []M{}})
-						return
-					}
-
-					for _, v := range cond.Values {
-						vs = append(vs, v)
-					}
-					q = q.Where(cond.Where, vs...)
-				}
-			case ov := <-orderChan:
-				for _, o := range ov {
-					v := reflect.ValueOf(m)
-					// Check if field exists in the model
-					if !strings.Contains(o.Field, ".") && !v.FieldByName(strcase.ToCamel(o.Field)).IsValid() {
-						errors = append(errors, fmt.Sprintf("Invalid field on the order by: %v", o.Field))
-						continue
-					}
-
-					q = q.Order(fmt.Sprintf("`%v` %v", o.Field, o.Modifier))
-				}
-
-				if len(errors) > 0 {
-					c.JSON(http.StatusBadRequest, gin.H{"errors": errors, "data": []M{}})
-					return
-				}
-			}
-		}
-
-		// LIMIT
-		limit := qmap.Get("limit")
-		if limit != "" {
-			limitI, err := strconv.Atoi(limit)
-			if err != nil {
-				errors = append(errors, fmt.Sprintf("Limit expects a number, received: %v", limit))
-			} else {
-				q = q.Limit(limitI)
-			}
-		}
-
-		if len(errors) > 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"errors": errors, "data": []M{}})
-			return
-		}
-
-		// OFFSET
-		offset := qmap.Get("offset")
-		if offset != "" {
-			offsetI, err := strconv.Atoi(offset)
-			if err != nil {
-				errors = append(errors, fmt.Sprintf("Offset expects a number, received: %v", offset))
-			} else {
-				if limit == "" {
-					q = q.Limit(20) // Default pagination to 20
-				}
-				q = q.Offset(offsetI)
-			}
-		}
-
-		if len(errors) > 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"errors": errors, "data": []M{}})
-			return
-		}
-
-		var results []map[string]interface{}
-		q.Find(&results)
-		fmt.Println("RESULTS: ", results)
-		c.JSON(http.StatusOK, gin.H{"data": results, "errors": errors})
-	})
-
-	r.GET(pathItem, func(c *gin.Context) {
-		err, item, _, _ := GetItem[M](c, config, "GET")
-		if err != nil {
-			return
-		}
+	return fields
+}
 
-		c.JSON(http.StatusOK, gin.H{"data": item})
-	})
+// jsonName returns the name of the field when serialized to JSON, false when it is skipped
+func jsonName(f reflect.StructField) (string, bool) {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return "", false
+	}
 
-	r.POST(path, func(c *gin.Context) {
-		var input M
-		if err := c.BindJSON(&input); err != nil {
-			ve, ok := err.(validator.ValidationErrors)
-			if !ok {
-				c.JSON(http.StatusInternalServerError, nil)
-				return
-			}
+	if name == "" {
+		return f.Name, true
+	}
 
-			errors := []string{}
-			for _, e := range ve {
-				errors = append(errors, fmt.Sprintf("%v : failed on tag validation: %v", e.Field(), e.ActualTag()))
-			}
+	return name, true
+}
 
-			c.JSON(http.StatusBadRequest, gin.H{"errors": errors})
-			return
-		}
+func IsTestRun() bool {
+	f := flag.Lookup("test.v")
+	if f == nil {
+		return false
+	}
 
-		if err := DB.WithContext(c).Create(&input).Error; err != nil {
-			me, ok := err.(*mysql.MySQLError)
-			if !ok {
-				c.JSON(http.StatusInternalServerError, nil)
-				return
-			}
+	return f.Value.(flag.Getter).Get().(bool)
+}
 
-			errors := []string{fmt.Sprintf("Error %v: %v", me.Number, me.Message)}
-			c.JSON(http.StatusBadRequest, gin.H{"errors": errors})
-			return
-		} else {
-			c.JSON(http.StatusCreated, gin.H{"data": input, "errors": []string{}})
-			return
-		}
-	})
+// Resource is a model exposed through the REST interface by RegisterModel
+type Resource[M any] struct {
+	name     string
+	model    M
+	config   *ApiConfig
+	router   gin.IRoutes
+	path     string
+	pathItem string
+	routes   []route
+
+	schemaOnce   sync.Once
+	parsedSchema *schema.Schema
+	schemaErr    error
+}
 
-	r.PUT(pathItem, func(c *gin.Context) {
-		var input M
-		err, item, _, _ := GetItem[M](c, config, "PUT")
-		if err != nil {
-			return
-		}
+func RegisterModel[M any](r *gin.Engine, m M, resource string, config *ApiConfig) *Resource[M] {
+	if config == nil {
+		config = &ApiConfig{}
+	}
 
-		c.ShouldBindJSON(&input)
+	validate()
 
-		if err := DB.WithContext(c).Model(&item).Updates(input).Error; err != nil {
-			me, ok := err.(*mysql.MySQLError)
-			if !ok {
-				c.JSON(http.StatusInternalServerError, nil)
-				return
-			}
+	if config.Cache != nil && config.Permission != nil && config.Cache.Vary == nil {
+		// the cached responses would be shared by the requests the Permission tells apart
+		panic(fmt.Sprintf("drilldown: the cache of %v needs Vary with a Permission", resource))
+	}
 
-			errors := []string{fmt.Sprintf("Error %v: %v", me.Number, me.Message)}
-			c.JSON(http.StatusBadRequest, gin.H{"errors": errors})
-			return
-		} else {
-			c.JSON(http.StatusNoContent, nil)
-		}
+	res := &Resource[M]{name: resource, model: m, config: config, router: r}
+	res.path = "/" + resource
+	res.pathItem = fmt.Sprintf("%v/%v", res.path, res.lookupPath())
+	path, pathItem := res.path, res.pathItem
+
+	model := reflect.TypeOf(m)
+	res.routes = mountRoutes(r, config, resource, []route{
+		{http.MethodGet, path, ActionList, res.list, model},
+		{http.MethodGet, pathItem, ActionRetrieve, res.retrieve, model},
+		{http.MethodPost, path, ActionCreate, res.create, model},
+		{http.MethodPut, pathItem, ActionUpdate, res.update, model},
+		{http.MethodDelete, pathItem, ActionDelete, res.delete, model},
 	})
+	if config.Audit != nil {
+		res.handle(route{http.MethodGet, fmt.Sprintf("%v/%v", pathItem, actionHistory), actionHistory, res.history, nil})
+	}
+	register(r, res)
 
-	r.DELETE(pathItem, func(c *gin.Context) {
-		err, item, idInt, idStr := GetItem[M](c, config, "DELETE")
-		if err != nil {
-			return
-		}
-
-		lookupField := "ID"
-		if config != nil && config.LookupField != "" {
-			lookupField = config.LookupField
-		}
-		lowerLookupField := strings.ToLower(lookupField)
-		whereClause := fmt.Sprintf("%s = ?", lowerLookupField)
-
-		if idStr != nil {
-			if err := DB.WithContext(c).Where(whereClause, idStr).Delete(&item).Error; err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Record not found!"})
-				return
-			}
-		} else {
-			if err := DB.WithContext(c).Where(whereClause, idInt).Delete(&item).Error; err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Record not found!"})
-				return
-			}
-		}
-
-		c.JSON(http.StatusNoContent, nil)
-	})
+	return res
 }
 
 func SetupRouter() *gin.Engine {
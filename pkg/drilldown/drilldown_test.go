@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -42,6 +43,29 @@ type Book struct {
 	CreatedAt uint64  `json:"created_at,omitempty"`
 }
 
+type Tag struct {
+	ID   uint64  `json:"id"`
+	Name *string `json:"name" binding:"required"`
+}
+
+type Article struct {
+	ID    uint64  `json:"id"`
+	Title *string `json:"title" binding:"required"`
+	Tags  []Tag   `json:"tags,omitempty" gorm:"many2many:article_tags"`
+}
+
+type Note struct {
+	ID       uint64  `json:"id"`
+	TenantID uint64  `json:"tenant_id"`
+	Text     *string `json:"text" binding:"required"`
+}
+
+type Document struct {
+	ID      uint64  `json:"id"`
+	Title   *string `json:"title" binding:"required"`
+	Version uint64  `json:"version" drilldown:"version"`
+}
+
 type ItemStringID struct {
 	ID   *string `json:"id" gorm:"primarykey"`
 	Name *string `json:"name" binding:"required"`
@@ -426,12 +450,12 @@ func TestQueries(t *testing.T) {
 	assert.Equal(t, float64(1), dataItems[0].(map[string]interface{})["id"])
 	assert.Equal(t, "Fight Club", dataItems[0].(map[string]interface{})["title"])
 	assert.Equal(t, float64(279), dataItems[0].(map[string]interface{})["pages"])
-	_, ok = dataItems[0].(map[string]interface{})["author.name"]
+	_, ok = dataItems[0].(map[string]interface{})["author"].(map[string]interface{})["name"]
 	assert.False(t, ok)
 	assert.Equal(t, float64(len(books)), dataItems[len(books)-1].(map[string]interface{})["id"])
 	assert.Equal(t, "Nightfall", dataItems[len(books)-1].(map[string]interface{})["title"])
 	assert.Equal(t, float64(501), dataItems[len(books)-1].(map[string]interface{})["pages"])
-	_, ok = dataItems[len(books)-1].(map[string]interface{})["author.name"]
+	_, ok = dataItems[len(books)-1].(map[string]interface{})["author"].(map[string]interface{})["name"]
 	assert.False(t, ok)
 
 	// Test query with invalid field
@@ -441,13 +465,13 @@ func TestQueries(t *testing.T) {
 	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 
+	response = map[string]interface{}{}
 	json.Unmarshal(w.Body.Bytes(), &response)
-	dataItems, _ = response["data"].([]interface{})
-	assert.Len(t, dataItems, 0)
+	assert.NotContains(t, response, "data")
 
 	errors := response["errors"]
 	assert.Len(t, errors, 1)
-	assert.Equal(t, "Invalid field on the fields selector: publisher", errors.([]interface{})[0])
+	assert.Equal(t, "Invalid field on the fields selector: publisher", errors.([]interface{})[0].(map[string]interface{})["message"])
 
 	// Test query with multiple invalid fields
 	w = httptest.NewRecorder()
@@ -456,14 +480,14 @@ func TestQueries(t *testing.T) {
 	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 
+	response = map[string]interface{}{}
 	json.Unmarshal(w.Body.Bytes(), &response)
-	dataItems, _ = response["data"].([]interface{})
-	assert.Len(t, dataItems, 0)
+	assert.NotContains(t, response, "data")
 
 	errors = response["errors"]
 	assert.Len(t, errors, 2)
-	assert.Equal(t, "Invalid field on the fields selector: publisher", errors.([]interface{})[0])
-	assert.Equal(t, "Invalid field on the fields selector: genri", errors.([]interface{})[1])
+	assert.Equal(t, "Invalid field on the fields selector: publisher", errors.([]interface{})[0].(map[string]interface{})["message"])
+	assert.Equal(t, "Invalid field on the fields selector: genri", errors.([]interface{})[1].(map[string]interface{})["message"])
 
 	// // Test query with a few fields and filtered by startswith
 	w = httptest.NewRecorder()
@@ -478,11 +502,11 @@ func TestQueries(t *testing.T) {
 	assert.Len(t, dataItems, 2)
 	assert.Equal(t, float64(1), dataItems[0].(map[string]interface{})["id"])
 	assert.Equal(t, "Fight Club", dataItems[0].(map[string]interface{})["title"])
-	assert.Equal(t, "Chuck Palahniuk", dataItems[0].(map[string]interface{})["author.name"])
+	assert.Equal(t, "Chuck Palahniuk", dataItems[0].(map[string]interface{})["author"].(map[string]interface{})["name"])
 
 	assert.Equal(t, float64(4), dataItems[1].(map[string]interface{})["id"])
 	assert.Equal(t, "Fight Story", dataItems[1].(map[string]interface{})["title"])
-	assert.Equal(t, "Robert E Howard", dataItems[1].(map[string]interface{})["author.name"])
+	assert.Equal(t, "Robert E Howard", dataItems[1].(map[string]interface{})["author"].(map[string]interface{})["name"])
 
 	// // Test query with a few fields and filtered by endswith
 	w = httptest.NewRecorder()
@@ -496,11 +520,11 @@ func TestQueries(t *testing.T) {
 	assert.Len(t, dataItems, 2)
 	assert.Equal(t, float64(4), dataItems[0].(map[string]interface{})["id"])
 	assert.Equal(t, "Fight Story", dataItems[0].(map[string]interface{})["title"])
-	assert.Equal(t, "Robert E Howard", dataItems[0].(map[string]interface{})["author.name"])
+	assert.Equal(t, "Robert E Howard", dataItems[0].(map[string]interface{})["author"].(map[string]interface{})["name"])
 
 	assert.Equal(t, float64(5), dataItems[1].(map[string]interface{})["id"])
 	assert.Equal(t, "American Horror Story", dataItems[1].(map[string]interface{})["title"])
-	assert.Equal(t, "Richard Greene", dataItems[1].(map[string]interface{})["author.name"])
+	assert.Equal(t, "Richard Greene", dataItems[1].(map[string]interface{})["author"].(map[string]interface{})["name"])
 
 	// Test query with a few fields and filtered by contains
 	w = httptest.NewRecorder()
@@ -635,11 +659,11 @@ func TestQueries(t *testing.T) {
 	dataItems, _ = response["data"].([]interface{})
 
 	assert.Len(t, dataItems, 8)
-	assert.Equal(t, "Anthony Burgess", dataItems[0].(map[string]interface{})["author.name"])
+	assert.Equal(t, "Anthony Burgess", dataItems[0].(map[string]interface{})["author"].(map[string]interface{})["name"])
 	assert.Equal(t, "Fight Club", dataItems[1].(map[string]interface{})["title"])
 	assert.Equal(t, "Nightfall", dataItems[4].(map[string]interface{})["title"])
-	assert.Equal(t, "Richard Greene", dataItems[6].(map[string]interface{})["author.name"])
-	assert.Equal(t, "Robert E Howard", dataItems[7].(map[string]interface{})["author.name"])
+	assert.Equal(t, "Richard Greene", dataItems[6].(map[string]interface{})["author"].(map[string]interface{})["name"])
+	assert.Equal(t, "Robert E Howard", dataItems[7].(map[string]interface{})["author"].(map[string]interface{})["name"])
 
 	// Test query with order by 2 fields
 	w = httptest.NewRecorder()
@@ -651,11 +675,11 @@ func TestQueries(t *testing.T) {
 	json.Unmarshal(w.Body.Bytes(), &response)
 	dataItems, _ = response["data"].([]interface{})
 	assert.Len(t, dataItems, 8)
-	assert.Equal(t, "Anthony Burgess", dataItems[0].(map[string]interface{})["author.name"])
+	assert.Equal(t, "Anthony Burgess", dataItems[0].(map[string]interface{})["author"].(map[string]interface{})["name"])
 	assert.Equal(t, "Survivor", dataItems[1].(map[string]interface{})["title"])
 	assert.Equal(t, "Prelude to Foundation", dataItems[4].(map[string]interface{})["title"])
-	assert.Equal(t, "Richard Greene", dataItems[6].(map[string]interface{})["author.name"])
-	assert.Equal(t, "Robert E Howard", dataItems[7].(map[string]interface{})["author.name"])
+	assert.Equal(t, "Richard Greene", dataItems[6].(map[string]interface{})["author"].(map[string]interface{})["name"])
+	assert.Equal(t, "Robert E Howard", dataItems[7].(map[string]interface{})["author"].(map[string]interface{})["name"])
 
 	// Test invalid field for order by
 	w = httptest.NewRecorder()
@@ -664,12 +688,12 @@ func TestQueries(t *testing.T) {
 	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 
+	response = map[string]interface{}{}
 	json.Unmarshal(w.Body.Bytes(), &response)
-	dataItems, _ = response["data"].([]interface{})
-	assert.Len(t, dataItems, 0)
+	assert.NotContains(t, response, "data")
 	errors = response["errors"]
 	assert.Len(t, errors, 1)
-	assert.Equal(t, "Invalid field on the order by: publisher", errors.([]interface{})[0])
+	assert.Equal(t, "Invalid field on the order by: publisher", errors.([]interface{})[0].(map[string]interface{})["message"])
 
 	// // Test invalid field for where clause
 	w = httptest.NewRecorder()
@@ -678,12 +702,12 @@ func TestQueries(t *testing.T) {
 	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 
+	response = map[string]interface{}{}
 	json.Unmarshal(w.Body.Bytes(), &response)
-	dataItems, _ = response["data"].([]interface{})
-	assert.Len(t, dataItems, 0)
+	assert.NotContains(t, response, "data")
 	errors = response["errors"]
 	assert.Len(t, errors, 1)
-	assert.Equal(t, "Invalid field on the condition: publisher", errors.([]interface{})[0])
+	assert.Equal(t, "Invalid field on the condition: publisher", errors.([]interface{})[0].(map[string]interface{})["message"])
 
 	// Test limit
 	w = httptest.NewRecorder()
@@ -696,8 +720,8 @@ func TestQueries(t *testing.T) {
 	dataItems, _ = response["data"].([]interface{})
 	fmt.Println("ERRORS: ", response["errors"])
 	assert.Len(t, dataItems, 2)
-	assert.Equal(t, "Anthony Burgess", dataItems[0].(map[string]interface{})["author.name"])
-	assert.Equal(t, "Chuck Palahniuk", dataItems[1].(map[string]interface{})["author.name"])
+	assert.Equal(t, "Anthony Burgess", dataItems[0].(map[string]interface{})["author"].(map[string]interface{})["name"])
+	assert.Equal(t, "Chuck Palahniuk", dataItems[1].(map[string]interface{})["author"].(map[string]interface{})["name"])
 
 	// Test offset
 	w = httptest.NewRecorder()
@@ -709,7 +733,7 @@ func TestQueries(t *testing.T) {
 	json.Unmarshal(w.Body.Bytes(), &response)
 	dataItems, _ = response["data"].([]interface{})
 	assert.Len(t, dataItems, 4)
-	assert.Equal(t, "Isaac Asimov", dataItems[0].(map[string]interface{})["author.name"])
+	assert.Equal(t, "Isaac Asimov", dataItems[0].(map[string]interface{})["author"].(map[string]interface{})["name"])
 
 	// Test limit & offset
 	w = httptest.NewRecorder()
@@ -721,7 +745,7 @@ func TestQueries(t *testing.T) {
 	json.Unmarshal(w.Body.Bytes(), &response)
 	dataItems, _ = response["data"].([]interface{})
 	assert.Len(t, dataItems, 2)
-	assert.Equal(t, "Chuck Palahniuk", dataItems[0].(map[string]interface{})["author.name"])
+	assert.Equal(t, "Chuck Palahniuk", dataItems[0].(map[string]interface{})["author"].(map[string]interface{})["name"])
 
 	// Test SQL Injection
 	w = httptest.NewRecorder()
@@ -850,7 +874,7 @@ func TestGetItem(t *testing.T) {
 	singleUrl = "/books/invalid-id"
 	req, _ = http.NewRequest(http.MethodGet, singleUrl, nil)
 	router.ServeHTTP(w, req)
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
 func TestGetItemStringID(t *testing.T) {
@@ -898,7 +922,7 @@ func TestInserts(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 	json.Unmarshal(w.Body.Bytes(), &response)
-	assert.Equal(t, "AuthorID : failed on tag validation: required", response["errors"].([]interface{})[0])
+	assert.Equal(t, "author_id is a required field", response["errors"].([]interface{})[0].(map[string]interface{})["message"])
 
 	// Test insert dependence
 	w = httptest.NewRecorder()
@@ -918,7 +942,7 @@ func TestInserts(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 	json.Unmarshal(w.Body.Bytes(), &response)
 	errors := response["errors"].([]interface{})
-	assert.Equal(t, "Error 1048: Column 'title' cannot be null", errors[0])
+	assert.Equal(t, "Error 1048: Column 'title' cannot be null", errors[0].(map[string]interface{})["message"])
 
 	// Test insert with all required and dependence
 	w = httptest.NewRecorder()
@@ -942,7 +966,7 @@ func TestInserts(t *testing.T) {
 	assert.Len(t, dataItems, 1)
 	assert.Equal(t, float64(1), dataItems[0].(map[string]interface{})["id"])
 	assert.Equal(t, "Fight Club", dataItems[0].(map[string]interface{})["title"])
-	assert.Equal(t, "Chuck Palahniuk", dataItems[0].(map[string]interface{})["author.name"])
+	assert.Equal(t, "Chuck Palahniuk", dataItems[0].(map[string]interface{})["author"].(map[string]interface{})["name"])
 
 	// Test insert duplicated unique key
 	w = httptest.NewRecorder()
@@ -951,7 +975,7 @@ func TestInserts(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 	json.Unmarshal(w.Body.Bytes(), &response)
-	assert.Equal(t, "Error 1062: Duplicate entry 'Chuck Palahniuk' for key 'authors.idx_name'", response["errors"].([]interface{})[0])
+	assert.Equal(t, "Error 1062: Duplicate entry 'Chuck Palahniuk' for key 'authors.idx_name'", response["errors"].([]interface{})[0].(map[string]interface{})["message"])
 }
 
 func TestUpdates(t *testing.T) {
@@ -996,6 +1020,24 @@ func TestUpdates(t *testing.T) {
 	assert.Equal(t, "Fight Club", data.(map[string]interface{})["title"])
 	assert.Equal(t, "drama", data.(map[string]interface{})["genre"])
 	assert.Equal(t, float64(279), data.(map[string]interface{})["pages"])
+
+	// Test Update with malformed body
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/books/%v", book.ID), bytes.NewBufferString(`{"genre": `))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Test Update validates the fields present
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/books/%v", book.ID), bytes.NewBufferString(`{"author_id": 0}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	response = map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	e := response["errors"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "author_id", e["field"])
+	assert.Equal(t, "author_id is a required field", e["message"])
 }
 
 func TestDeletes(t *testing.T) {
@@ -1032,7 +1074,7 @@ func TestDeletes(t *testing.T) {
 	singleUrl = "/books/invalid-id"
 	req, _ = http.NewRequest(http.MethodDelete, singleUrl, nil)
 	router.ServeHTTP(w, req)
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 
 	// Test Get item after deletion
 	w = httptest.NewRecorder()
@@ -1041,3 +1083,1787 @@ func TestDeletes(t *testing.T) {
 	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusNotFound, w.Code)
 }
+
+func TestHooks(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	DB.AutoMigrate(&Author{})
+	RegisterModel(router, Book{}, "books", &ApiConfig{
+		BeforeCreate: func(c *gin.Context, tx *gorm.DB, item interface{}) error {
+			item.(*Book).Genre = stringPtr("Unknown")
+			return nil
+		},
+		BeforeDelete: func(c *gin.Context, tx *gorm.DB, item interface{}) error {
+			return NewHTTPError(http.StatusForbidden, "Books can't be deleted")
+		},
+		BeforeRead: func(c *gin.Context, tx *gorm.DB, item interface{}) error {
+			tx.Where("genre IS NOT NULL")
+			return nil
+		},
+	})
+
+	author := Author{Name: stringPtr("Chuck Palahniuk")}
+	DB.Create(&author)
+
+	var response map[string]interface{}
+
+	// Test hook mutating the item before creation
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/books", bytes.NewBufferString(fmt.Sprintf(`{"title":"Fight Club", "author_id": %v}`, author.ID)))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	json.Unmarshal(w.Body.Bytes(), &response)
+	data := response["data"].(map[string]interface{})
+	assert.Equal(t, "Unknown", data["genre"])
+
+	// Test hook aborting the deletion
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("/books/%v", data["id"]), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "Books can't be deleted", response["errors"].([]interface{})[0].(map[string]interface{})["message"])
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v", data["id"]), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Test hook restricting the lookup
+	survivor := Book{Title: stringPtr("Survivor"), AuthorID: author.ID}
+	DB.Create(&survivor)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v", survivor.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// AuthorBooksPermission lets the authors see only their own books and forbids deletions
+type AuthorBooksPermission struct {
+	AllowAll
+}
+
+func (AuthorBooksPermission) HasPermission(c *gin.Context, action Action) bool {
+	return action != ActionDelete
+}
+
+func (AuthorBooksPermission) Filter(c *gin.Context, db *gorm.DB) *gorm.DB {
+	return db.Where("author_id = ?", c.GetHeader("X-Author"))
+}
+
+func TestPermissions(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	DB.AutoMigrate(&Author{})
+	RegisterModel(router, Book{}, "books", &ApiConfig{Permission: AuthorBooksPermission{}})
+
+	chuckPalahniuk := Author{Name: stringPtr("Chuck Palahniuk")}
+	isaacAsimov := Author{Name: stringPtr("Isaac Asimov")}
+	DB.Create(&chuckPalahniuk)
+	DB.Create(&isaacAsimov)
+
+	fightClub := Book{Title: stringPtr("Fight Club"), AuthorID: chuckPalahniuk.ID}
+	nightfall := Book{Title: stringPtr("Nightfall"), AuthorID: isaacAsimov.ID}
+	DB.Create(&fightClub)
+	DB.Create(&nightfall)
+
+	var response map[string]interface{}
+
+	// Test list only own books
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/books", nil)
+	req.Header.Set("X-Author", fmt.Sprint(chuckPalahniuk.ID))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	json.Unmarshal(w.Body.Bytes(), &response)
+	dataItems := response["data"].([]interface{})
+	assert.Len(t, dataItems, 1)
+	assert.Equal(t, "Fight Club", dataItems[0].(map[string]interface{})["title"])
+
+	// Test get book from another author
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v", nightfall.ID), nil)
+	req.Header.Set("X-Author", fmt.Sprint(chuckPalahniuk.ID))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Test update book from another author
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/books/%v", nightfall.ID), bytes.NewBufferString(`{"pages": 10}`))
+	req.Header.Set("X-Author", fmt.Sprint(chuckPalahniuk.ID))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Test delete not allowed
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("/books/%v", fightClub.ID), nil)
+	req.Header.Set("X-Author", fmt.Sprint(chuckPalahniuk.ID))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestActions(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Author{})
+	RegisterModel(router, Author{}, "authors", &ApiConfig{Actions: ReadOnlyActions})
+
+	author := Author{Name: stringPtr("Chuck Palahniuk")}
+	DB.Create(&author)
+
+	// Test enabled action
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/authors/%v", author.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Test disabled create
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/authors", bytes.NewBufferString(`{"name":"Isaac Asimov"}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET", w.Header().Get("Allow"))
+
+	// Test disabled delete
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("/authors/%v", author.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET", w.Header().Get("Allow"))
+}
+
+func TestCustomActions(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	DB.AutoMigrate(&Author{})
+	RegisterModel(router, Book{}, "books", nil).
+		ItemAction(http.MethodPost, "publish", func(c *gin.Context, tx *gorm.DB, book *Book) error {
+			if book.Genre != nil {
+				return NewHTTPError(http.StatusConflict, "Book already published")
+			}
+
+			if err := tx.Model(book).Update("genre", "Published").Error; err != nil {
+				return err
+			}
+
+			c.JSON(http.StatusOK, gin.H{"data": book})
+			return nil
+		}).
+		CollectionAction(http.MethodGet, "stats", func(c *gin.Context, db *gorm.DB) error {
+			var count int64
+			if err := db.Count(&count).Error; err != nil {
+				return err
+			}
+
+			c.JSON(http.StatusOK, gin.H{"data": gin.H{"count": count}})
+			return nil
+		})
+
+	author := Author{Name: stringPtr("Chuck Palahniuk")}
+	DB.Create(&author)
+
+	book := Book{Title: stringPtr("Fight Club"), AuthorID: author.ID}
+	DB.Create(&book)
+
+	var response map[string]interface{}
+
+	// Test item action
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/books/%v/publish", book.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "Published", response["data"].(map[string]interface{})["genre"])
+
+	// Test item action returning an error
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/books/%v/publish", book.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Test item action on non existent item
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/books/999/publish", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Test collection action
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books/stats", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, float64(1), response["data"].(map[string]interface{})["count"])
+}
+
+func TestNested(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	DB.AutoMigrate(&Author{})
+	authors := RegisterModel(router, Author{}, "authors", nil)
+	books := RegisterModel(router, Book{}, "books", nil)
+	RegisterNested(authors, books, "Books")
+
+	chuckPalahniuk := Author{Name: stringPtr("Chuck Palahniuk")}
+	isaacAsimov := Author{Name: stringPtr("Isaac Asimov")}
+	DB.Create(&chuckPalahniuk)
+	DB.Create(&isaacAsimov)
+
+	DB.Create(&Book{Title: stringPtr("Fight Club"), AuthorID: chuckPalahniuk.ID, Pages: intPtr(279)})
+	DB.Create(&Book{Title: stringPtr("Survivor"), AuthorID: chuckPalahniuk.ID, Pages: intPtr(353)})
+	DB.Create(&Book{Title: stringPtr("Nightfall"), AuthorID: isaacAsimov.ID, Pages: intPtr(501)})
+
+	var response map[string]interface{}
+	path := fmt.Sprintf("/authors/%v/books", chuckPalahniuk.ID)
+
+	// Test list constrained to the parent
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Len(t, response["data"].([]interface{}), 2)
+
+	// Test list with filters and order
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, path+"?pages__gt=300&order=-pages", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	json.Unmarshal(w.Body.Bytes(), &response)
+	dataItems := response["data"].([]interface{})
+	assert.Len(t, dataItems, 1)
+	assert.Equal(t, "Survivor", dataItems[0].(map[string]interface{})["title"])
+
+	// Test create setting the foreign key
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, path, bytes.NewBufferString(fmt.Sprintf(`{"title":"Haunted", "author_id": %v}`, isaacAsimov.ID)))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, float64(chuckPalahniuk.ID), response["data"].(map[string]interface{})["author_id"])
+
+	// Test non existent parent
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/authors/999/books", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestManyToMany(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Tag{})
+	DB.AutoMigrate(&Article{})
+	articles := RegisterModel(router, Article{}, "articles", nil)
+	tags := RegisterModel(router, Tag{}, "tags", nil)
+	RegisterManyToMany(articles, tags, "Tags")
+
+	article := Article{Title: stringPtr("Gin and Gorm")}
+	DB.Create(&article)
+	golang := Tag{Name: stringPtr("golang")}
+	rest := Tag{Name: stringPtr("rest")}
+	DB.Create(&golang)
+	DB.Create(&rest)
+
+	var response map[string]interface{}
+	path := fmt.Sprintf("/articles/%v/tags", article.ID)
+
+	// Test attach tag
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%v/%v", path, golang.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Test attach non existent tag
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, path+"/999", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Test attach to non existent article
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/articles/999/tags/%v", golang.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Test replace tags
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, path, bytes.NewBufferString(fmt.Sprintf("[%v, %v]", golang.ID, rest.ID)))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Test detach tag
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("%v/%v", path, golang.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Test list tags
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, path, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	json.Unmarshal(w.Body.Bytes(), &response)
+	dataItems := response["data"].([]interface{})
+	assert.Len(t, dataItems, 1)
+	assert.Equal(t, "rest", dataItems[0].(map[string]interface{})["name"])
+
+	// Test relationship writes audited and disabled with the update action
+	DB.AutoMigrate(&AuditEntry{})
+	audited := SetupRouter()
+	auditedArticles := RegisterModel(audited, Article{}, "articles", &ApiConfig{Audit: &Audit{}})
+	RegisterManyToMany(auditedArticles, RegisterModel(audited, Tag{}, "tags", nil), "Tags")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("%v/%v", path, golang.ID), nil)
+	audited.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	var entry AuditEntry
+	DB.Last(&entry)
+	assert.Equal(t, ActionUpdate, entry.Action)
+	assert.JSONEq(t, `{"tags":{"before":[{"id":2,"name":"rest"}],"after":[{"id":1,"name":"golang"},{"id":2,"name":"rest"}]}}`, string(entry.Changes))
+
+	readOnly := SetupRouter()
+	readOnlyArticles := RegisterModel(readOnly, Article{}, "articles", &ApiConfig{Actions: ReadOnlyActions})
+	RegisterManyToMany(readOnlyArticles, RegisterModel(readOnly, Tag{}, "tags", nil), "Tags")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, path, bytes.NewBufferString("[]"))
+	readOnly.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("%v/%v", path, rest.ID), nil)
+	readOnly.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, []string{""}, w.Header().Values("Allow"))
+
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "Method not allowed", response["errors"].([]interface{})[0].(map[string]interface{})["message"])
+
+	writeOnly := SetupRouter()
+	writeOnlyArticles := RegisterModel(writeOnly, Article{}, "articles", &ApiConfig{Actions: []Action{ActionUpdate}})
+	RegisterManyToMany(writeOnlyArticles, RegisterModel(writeOnly, Tag{}, "tags", nil), "Tags")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, path, nil)
+	writeOnly.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "PUT", w.Header().Get("Allow"))
+}
+
+func TestOpenAPI(t *testing.T) {
+	router := SetupRouter()
+	RegisterModel(router, Book{}, "books", nil).
+		ItemAction(http.MethodPost, "publish", func(c *gin.Context, tx *gorm.DB, book *Book) error { return nil })
+	RegisterModel(router, Author{}, "authors", &ApiConfig{Actions: ReadOnlyActions})
+	RegisterOpenAPI(router, &OpenAPIConfig{Title: "Library", DocsPath: "/docs"})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/openapi.json", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var spec map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &spec)
+	assert.Equal(t, "3.1.0", spec["openapi"])
+	assert.Equal(t, "Library", spec["info"].(map[string]interface{})["title"])
+
+	paths := spec["paths"].(map[string]interface{})
+	assert.Contains(t, paths, "/books")
+	assert.Contains(t, paths, "/books/{id}")
+	assert.Contains(t, paths, "/books/{id}/publish")
+	assert.Contains(t, paths["/books"], "post")
+	assert.NotContains(t, paths["/authors"], "post")
+	assert.NotContains(t, paths, "/authors/{id}/publish")
+
+	// Test list parameters
+	params := []string{}
+	for _, p := range paths["/books"].(map[string]interface{})["get"].(map[string]interface{})["parameters"].([]interface{}) {
+		params = append(params, p.(map[string]interface{})["name"].(string))
+	}
+	assert.Contains(t, params, "fields")
+	assert.Contains(t, params, "order")
+	assert.Contains(t, params, "limit")
+	assert.Contains(t, params, "offset")
+	assert.Contains(t, params, "pages__gte")
+	assert.Contains(t, params, "title__contains")
+
+	// Test model schemas
+	schemas := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	book := schemas["Book"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"author_id"}, book["required"])
+	assert.Contains(t, book["properties"], "title")
+
+	// Test docs page
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/docs", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<title>Library</title>")
+}
+
+func TestBrowsable(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	DB.AutoMigrate(&Author{})
+	RegisterModel(router, Book{}, "books", nil)
+
+	author := Author{Name: stringPtr("Chuck Palahniuk")}
+	DB.Create(&author)
+	for i := 0; i < 3; i++ {
+		DB.Create(&Book{Title: stringPtr(fmt.Sprintf("Book %v", i)), AuthorID: author.ID})
+	}
+
+	// Test list page with pagination
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/books?limit=2", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "Book 1")
+	assert.Contains(t, w.Body.String(), `href="/books?limit=2&amp;offset=2"`)
+	assert.Contains(t, w.Body.String(), `<select data-field="pages">`)
+	assert.Contains(t, w.Body.String(), `<input id="author_id" name="author_id" type="number" value="" required>`)
+
+	// Test item page with the edit form
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books/1", nil)
+	req.Header.Set("Accept", "text/html")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<input id="title" name="title" type="text" value="Book 0" >`)
+
+	// Test JSON still the default
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books/1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+}
+
+func TestRenderers(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	DB.AutoMigrate(&Author{})
+	RegisterModel(router, Book{}, "books", nil)
+	RegisterRenderer("text", "text/plain", RendererFunc(func(c *gin.Context, status int, response *Response) error {
+		c.String(status, "%v rows", len(response.Rows()))
+		return nil
+	}))
+
+	author := Author{Name: stringPtr("Chuck Palahniuk")}
+	DB.Create(&author)
+	DB.Create(&Book{Title: stringPtr("Fight Club"), AuthorID: author.ID, Pages: intPtr(279)})
+	DB.Create(&Book{Title: stringPtr("Survivor"), AuthorID: author.ID, Pages: intPtr(353)})
+
+	// Test CSV respecting the fields order
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/books?format=csv&fields=pages,title,authors.name", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "pages,title,author.name\n279,Fight Club,Chuck Palahniuk\n353,Survivor,Chuck Palahniuk\n", w.Body.String())
+
+	// Test NDJSON by the Accept header
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?fields=title", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "{\"id\":1,\"title\":\"Fight Club\"}\n{\"id\":2,\"title\":\"Survivor\"}\n", w.Body.String())
+
+	// Test XML single item
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books/1", nil)
+	req.Header.Set("Accept", "application/xml")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<data><id>1</id><title>Fight Club</title>")
+
+	// Test custom renderer
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?format=text", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2 rows", w.Body.String())
+
+	// Test unknown format
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?format=pdf", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+
+	// Test large numbers keep their integer format
+	book := Book{Title: stringPtr("Tell-All"), AuthorID: author.ID, Pages: intPtr(1234567)}
+	DB.Create(&book)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v?format=csv", book.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), fmt.Sprintf("%v,Tell-All,%v,,1234567,,%v,%v\n", book.ID, author.ID, book.UpdatedAt, book.CreatedAt))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v", book.ID), nil)
+	req.Header.Set("Accept", "application/xml")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), fmt.Sprintf("<pages>1234567</pages><updated_at>%v</updated_at>", book.UpdatedAt))
+}
+
+func TestStreaming(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	DB.AutoMigrate(&Author{})
+	RegisterModel(router, Book{}, "books", nil)
+
+	author := Author{Name: stringPtr("Chuck Palahniuk")}
+	DB.Create(&author)
+	books := []Book{}
+	for i := 0; i < 250; i++ {
+		books = append(books, Book{Title: stringPtr(fmt.Sprintf("Book %v", i)), AuthorID: author.ID, Pages: intPtr(i)})
+	}
+	DB.Create(&books)
+
+	var response map[string]interface{}
+
+	// Test streamed JSON
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/books?stream=true&fields=title,pages&order=pages", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	json.Unmarshal(w.Body.Bytes(), &response)
+	dataItems := response["data"].([]interface{})
+	assert.Len(t, dataItems, 250)
+	assert.Equal(t, "Book 0", dataItems[0].(map[string]interface{})["title"])
+	assert.Equal(t, float64(249), dataItems[249].(map[string]interface{})["pages"])
+
+	// Test streamed CSV with filters
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?stream=true&format=csv&fields=title,pages&pages__lt=2&order=pages", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "title,pages\nBook 0,0\nBook 1,1\n", w.Body.String())
+
+	// Test streamed NDJSON with no results
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?stream=true&format=ndjson&pages__gt=1000", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "", w.Body.String())
+
+	// Test invalid stream parameter
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?stream=maybe", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Test the AfterList hook redacting and removing streamed rows
+	redacted := SetupRouter()
+	RegisterModel(redacted, Book{}, "books", &ApiConfig{AfterList: func(c *gin.Context, tx *gorm.DB, item interface{}) error {
+		items := item.(*[]Book)
+		if *(*items)[0].Pages%2 == 1 {
+			*items = (*items)[:0]
+			return nil
+		}
+		(*items)[0].Title = stringPtr("Redacted")
+		return nil
+	}})
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?stream=true&format=csv&fields=title,pages&pages__lt=4&order=pages", nil)
+	redacted.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "title,pages\nRedacted,0\nRedacted,2\n", w.Body.String())
+}
+
+func TestTypedList(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	DB.AutoMigrate(&Author{})
+	RegisterModel(router, Book{}, "books", nil)
+
+	author := Author{Name: stringPtr("Chuck Palahniuk")}
+	DB.Create(&author)
+	book := Book{Title: stringPtr("Fight Club"), AuthorID: author.ID, Pages: intPtr(279)}
+	DB.Create(&book)
+
+	// Test list and single item serialized identically
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/books", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var listResponse map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &listResponse)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v", book.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var itemResponse map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &itemResponse)
+	assert.Equal(t, itemResponse["data"], listResponse["data"].([]interface{})[0])
+	assert.Equal(t, float64(279), itemResponse["data"].(map[string]interface{})["pages"])
+
+	// Test projection with nested related fields
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?fields=pages,authors.name", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	json.Unmarshal(w.Body.Bytes(), &listResponse)
+	dataItem := listResponse["data"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"id":     float64(book.ID),
+		"pages":  float64(279),
+		"author": map[string]interface{}{"name": "Chuck Palahniuk"},
+	}, dataItem)
+
+	// Test projection keeping the precision of large keys
+	DB.Create(&Book{ID: 9007199254740993, Title: stringPtr("Invisible Monsters"), AuthorID: author.ID})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?fields=title&title=Invisible Monsters", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `{"id":9007199254740993,"title":"Invisible Monsters"}`)
+
+	// Test NULL in a column of a field that is not a pointer
+	DB.Exec("UPDATE books SET author_id = NULL WHERE id = ?", book.ID)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?title=Fight Club", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	json.Unmarshal(w.Body.Bytes(), &listResponse)
+	dataItem = listResponse["data"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Fight Club", dataItem["title"])
+	assert.NotContains(t, dataItem, "author_id")
+}
+
+func TestErrors(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	DB.AutoMigrate(&Author{})
+	RegisterModel(router, Book{}, "books", nil)
+	RegisterModel(router, Author{}, "authors", &ApiConfig{Actions: ReadOnlyActions, ErrorFormatter: ProblemJSON})
+
+	// Test not found
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/books/999", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"code": "not_found", "message": "Record not found!"},
+	}, response["errors"])
+
+	// Test validation error
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/books", bytes.NewBufferString(`{"title":"Fight Club"}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	response = map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	e := response["errors"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "validation_failed", e["code"])
+	assert.Equal(t, "author_id", e["field"])
+
+	// Test malformed body
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/books", bytes.NewBufferString(`{"title":`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	response = map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "invalid_body", response["errors"].([]interface{})[0].(map[string]interface{})["code"])
+
+	// Test body that is not an object
+	book := Book{Title: stringPtr("Fight Club"), AuthorID: 1}
+	DB.Create(&book)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/books/%v", book.ID), bytes.NewBufferString(`[1]`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	response = map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"code": "invalid_body", "message": "The body must be a JSON object"},
+	}, response["errors"])
+
+	for _, r := range []struct{ method, url, body string }{
+		{http.MethodPut, fmt.Sprintf("/books/%v", book.ID), `null`},
+		{http.MethodPost, "/books", `[]`},
+		{http.MethodPost, "/books", `"Fight Club"`},
+	} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(r.method, r.url, bytes.NewBufferString(r.body))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		response = map[string]interface{}{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"code": "invalid_body", "message": "The body must be a JSON object"},
+		}, response["errors"])
+	}
+
+	// Test invalid parameter
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?limit=ten", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	response = map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	e = response["errors"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "invalid_parameter", e["code"])
+	assert.Equal(t, "limit", e["field"])
+
+	// Test problem details formatter
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/authors", bytes.NewBufferString(`{"name":"Isaac Asimov"}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+	response = map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "Method Not Allowed", response["title"])
+	assert.Equal(t, float64(http.StatusMethodNotAllowed), response["status"])
+	assert.Equal(t, "Method not allowed", response["detail"])
+
+	// Test the errors are recorded for the middlewares
+	var recorded error
+	router = SetupRouter()
+	router.Use(func(c *gin.Context) {
+		c.Next()
+		recorded = c.Errors.Last()
+	})
+	RegisterModel(router, Book{}, "books", &ApiConfig{
+		AfterRead: func(c *gin.Context, tx *gorm.DB, item interface{}) error {
+			return fmt.Errorf("unavailable")
+		},
+	})
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v", book.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.EqualError(t, recorded, "unavailable")
+}
+
+func TestValidators(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	DB.AutoMigrate(&Author{})
+	RegisterModel(router, Book{}, "books", &ApiConfig{Validators: []Validator{
+		Unique("slug"),
+		Exists[Author]("author_id"),
+	}})
+
+	author := Author{Name: stringPtr("Chuck Palahniuk")}
+	DB.Create(&author)
+	book := Book{Title: stringPtr("Fight Club"), AuthorID: author.ID, Slug: stringPtr("fight-club")}
+	DB.Create(&book)
+	survivor := Book{Title: stringPtr("Survivor"), AuthorID: author.ID, Slug: stringPtr("survivor")}
+	DB.Create(&survivor)
+
+	// Test all the failures are reported
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/books", bytes.NewBufferString(`{"title":"Haunted","author_id":999,"slug":"fight-club"}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	errors := response["errors"].([]interface{})
+	assert.Len(t, errors, 2)
+	assert.Equal(t, "slug", errors[0].(map[string]interface{})["field"])
+	assert.Equal(t, "slug must be unique", errors[0].(map[string]interface{})["message"])
+	assert.Equal(t, "author_id", errors[1].(map[string]interface{})["field"])
+	assert.Equal(t, "author_id does not exist", errors[1].(map[string]interface{})["message"])
+
+	// Test valid create
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/books", bytes.NewBufferString(fmt.Sprintf(`{"title":"Haunted","author_id":%v,"slug":"haunted"}`, author.ID)))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// Test update keeping its own value
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/books/%v", book.ID), bytes.NewBufferString(`{"slug":"fight-club","pages":279}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Test update with a value of another row
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/books/%v", book.ID), bytes.NewBufferString(`{"slug":"survivor"}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var count int64
+	DB.Model(&Book{}).Where("slug = ?", "survivor").Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestSearch(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	DB.AutoMigrate(&Author{})
+	RegisterModel(router, Book{}, "books", &ApiConfig{Search: &SearchConfig{Fields: []string{"title", "authors.name"}}})
+	RegisterModel(router, Author{}, "authors", nil)
+
+	chuckPalahniuk := Author{Name: stringPtr("Chuck Palahniuk")}
+	DB.Create(&chuckPalahniuk)
+	isaacAsimov := Author{Name: stringPtr("Isaac Asimov")}
+	DB.Create(&isaacAsimov)
+	DB.Create(&Book{Title: stringPtr("Fight Club"), AuthorID: chuckPalahniuk.ID})
+	DB.Create(&Book{Title: stringPtr("Survivor"), AuthorID: chuckPalahniuk.ID})
+	DB.Create(&Book{Title: stringPtr("Nightfall"), AuthorID: isaacAsimov.ID})
+
+	// Test search on related field
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/books?search=palahniuk&fields=title,authors.name", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	dataItems := response["data"].([]interface{})
+	assert.Len(t, dataItems, 2)
+	assert.Equal(t, "Fight Club", dataItems[0].(map[string]interface{})["title"])
+	assert.Equal(t, "Survivor", dataItems[1].(map[string]interface{})["title"])
+
+	// Test search on local field combined with filters
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?search=asimov&title__startswith=Night", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response = map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	dataItems = response["data"].([]interface{})
+	assert.Len(t, dataItems, 1)
+	assert.Equal(t, "Nightfall", dataItems[0].(map[string]interface{})["title"])
+
+	// Test search with the LIKE wildcards matched literally
+	DB.Create(&Book{Title: stringPtr("100% Asimov"), AuthorID: isaacAsimov.ID})
+	for term, count := range map[string]int{"%25": 1, "_": 0, "0!": 0} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/books?search="+term, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		response = map[string]interface{}{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Len(t, response["data"].([]interface{}), count, term)
+	}
+
+	// Test search not enabled
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/authors?search=asimov", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Test search on several fields of the same related table
+	joined := SetupRouter()
+	RegisterModel(joined, Book{}, "books", &ApiConfig{Search: &SearchConfig{Fields: []string{"title", "authors.name", "authors.id"}}})
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?search=asimov", nil)
+	joined.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response = map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Len(t, response["data"].([]interface{}), 2)
+}
+
+func TestScopes(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	DB.AutoMigrate(&Author{})
+	RegisterModel(router, Book{}, "books", &ApiConfig{Scopes: map[string]Scope{
+		"scifi": {Description: "Science fiction books", Apply: func(db *gorm.DB, arg interface{}) *gorm.DB {
+			return db.Where("genre = ?", "SciFi")
+		}},
+		"long": {Apply: func(db *gorm.DB, arg interface{}) *gorm.DB {
+			return db.Where("pages > ?", 300)
+		}},
+		"min_pages": {Param: "integer", Apply: func(db *gorm.DB, arg interface{}) *gorm.DB {
+			return db.Where("pages >= ?", arg)
+		}},
+	}})
+
+	author := Author{Name: stringPtr("Isaac Asimov")}
+	DB.Create(&author)
+	DB.Create(&Book{Title: stringPtr("Nightfall"), AuthorID: author.ID, Pages: intPtr(501), Genre: stringPtr("SciFi")})
+	DB.Create(&Book{Title: stringPtr("The Last Question"), AuthorID: author.ID, Pages: intPtr(20), Genre: stringPtr("SciFi")})
+	DB.Create(&Book{Title: stringPtr("Asimov's Guide to the Bible"), AuthorID: author.ID, Pages: intPtr(1300)})
+
+	// Test composed scopes
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/books?scope=scifi,long", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	dataItems := response["data"].([]interface{})
+	assert.Len(t, dataItems, 1)
+	assert.Equal(t, "Nightfall", dataItems[0].(map[string]interface{})["title"])
+
+	// Test scope with argument and filter
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?min_pages=100&genre=SciFi", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response = map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	dataItems = response["data"].([]interface{})
+	assert.Len(t, dataItems, 1)
+	assert.Equal(t, "Nightfall", dataItems[0].(map[string]interface{})["title"])
+
+	// Test invalid scopes
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?scope=short", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?min_pages=many", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	response = map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "min_pages expects an integer, received: many", response["errors"].([]interface{})[0].(map[string]interface{})["message"])
+
+	// Test scopes documented
+	spec := OpenAPI(router, nil)
+	params := spec["paths"].(gin.H)["/books"].(gin.H)["get"].(gin.H)["parameters"].([]gin.H)
+	names := []string{}
+	for _, p := range params {
+		names = append(names, p["name"].(string))
+	}
+	assert.Contains(t, names, "scope")
+	assert.Contains(t, names, "min_pages")
+}
+
+func TestMutationScopes(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	DB.AutoMigrate(&Author{})
+	RegisterModel(router, Book{}, "books", &ApiConfig{
+		ScopesFind: []func(db *gorm.DB) *gorm.DB{FilterSciFi},
+	})
+	RegisterModel(router, Author{}, "authors", &ApiConfig{
+		ScopesFind: []func(db *gorm.DB) *gorm.DB{func(db *gorm.DB) *gorm.DB {
+			return db.Where("name LIKE ?", "Isaac%")
+		}},
+		ScopesDelete: []func(db *gorm.DB) *gorm.DB{},
+	})
+
+	isaacAsimov := Author{Name: stringPtr("Isaac Asimov")}
+	DB.Create(&isaacAsimov)
+	chuckPalahniuk := Author{Name: stringPtr("Chuck Palahniuk")}
+	DB.Create(&chuckPalahniuk)
+	fightClub := Book{Title: stringPtr("Fight Club"), AuthorID: chuckPalahniuk.ID, Pages: intPtr(279)}
+	DB.Create(&fightClub)
+	nightfall := Book{Title: stringPtr("Nightfall"), AuthorID: isaacAsimov.ID, Pages: intPtr(501), Genre: stringPtr("scifi")}
+	DB.Create(&nightfall)
+
+	// Test update and delete default to the read scopes
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPut, fmt.Sprintf("/books/%v", fightClub.ID), bytes.NewBufferString(`{"pages": 10}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("/books/%v", fightClub.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var book Book
+	DB.First(&book, fightClub.ID)
+	assert.Equal(t, 279, *book.Pages)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/books/%v", nightfall.ID), bytes.NewBufferString(`{"pages": 10}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Test empty delete scopes
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/authors/%v", chuckPalahniuk.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	DB.Model(&Book{}).Where("author_id = ?", chuckPalahniuk.ID).Delete(&Book{})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("/authors/%v", chuckPalahniuk.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestTenancy(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Note{})
+	RegisterModel(router, Note{}, "notes", &ApiConfig{
+		Tenancy: &Tenancy{Resolve: TenantFromHeader("X-Tenant")},
+	})
+
+	first := Note{TenantID: 1, Text: stringPtr("First tenant")}
+	DB.Create(&first)
+	second := Note{TenantID: 2, Text: stringPtr("Second tenant")}
+	DB.Create(&second)
+
+	request := func(method string, url string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("X-Tenant", "1")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Test tenant required
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/notes", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Test list scoped by tenant
+	w = request(http.MethodGet, "/notes", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	dataItems := response["data"].([]interface{})
+	assert.Len(t, dataItems, 1)
+	assert.Equal(t, "First tenant", dataItems[0].(map[string]interface{})["text"])
+
+	// Test rows of other tenants not reachable
+	w = request(http.MethodGet, fmt.Sprintf("/notes/%v", second.ID), "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = request(http.MethodPut, fmt.Sprintf("/notes/%v", second.ID), `{"text":"Changed"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = request(http.MethodDelete, fmt.Sprintf("/notes/%v", second.ID), "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Test tenant set on create
+	w = request(http.MethodPost, "/notes", `{"text":"Created","tenant_id":2}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created Note
+	DB.Where("text = ?", "Created").First(&created)
+	assert.Equal(t, uint64(1), created.TenantID)
+
+	// Test tenant change rejected
+	w = request(http.MethodPut, fmt.Sprintf("/notes/%v", first.ID), `{"tenant_id":2}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = request(http.MethodPut, fmt.Sprintf("/notes/%v", first.ID), `{"tenant_id":1,"text":"Changed"}`)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Test validators limited to the rows of the tenant
+	router = SetupRouter()
+	RegisterModel(router, Note{}, "notes", &ApiConfig{
+		Tenancy:    &Tenancy{Resolve: TenantFromHeader("X-Tenant")},
+		Validators: []Validator{Unique("text")},
+	})
+
+	w = request(http.MethodPost, "/notes", `{"text":"Second tenant"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = request(http.MethodPost, "/notes", `{"text":"Created"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestLookup(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	DB.AutoMigrate(&Author{})
+	RegisterModel(router, Book{}, "books", &ApiConfig{LookupKey: []string{"AuthorID", "Slug"}})
+	RegisterModel(router, Author{}, "authors", &ApiConfig{LookupAlternatives: []string{"Name"}})
+
+	isaacAsimov := Author{Name: stringPtr("Isaac Asimov")}
+	DB.Create(&isaacAsimov)
+	chuckPalahniuk := Author{Name: stringPtr("Chuck Palahniuk")}
+	DB.Create(&chuckPalahniuk)
+	nightfall := Book{Title: stringPtr("Nightfall"), AuthorID: isaacAsimov.ID, Slug: stringPtr("nightfall")}
+	DB.Create(&nightfall)
+	fightClub := Book{Title: stringPtr("Fight Club"), AuthorID: chuckPalahniuk.ID, Slug: stringPtr("fight-club")}
+	DB.Create(&fightClub)
+
+	// Test composite key
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v/nightfall", isaacAsimov.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	dataItem, _ := response["data"].(map[string]interface{})
+	assert.Equal(t, "Nightfall", dataItem["title"])
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v/nightfall", chuckPalahniuk.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Test malformed part of the key
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books/isaac/nightfall", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	response = map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	errors, _ := response["errors"].([]interface{})
+	assert.Equal(t, "author_id", errors[0].(map[string]interface{})["field"])
+
+	// Test update and delete by the composite key
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/books/%v/fight-club", chuckPalahniuk.ID), bytes.NewBufferString(`{"pages": 208}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	var book Book
+	DB.First(&book, fightClub.ID)
+	assert.Equal(t, 208, *book.Pages)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("/books/%v/fight-club", chuckPalahniuk.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	var count int64
+	DB.Model(&Book{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+
+	// Test alternative lookup fields
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/authors/%v", chuckPalahniuk.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/authors/Isaac%20Asimov", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response = map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	dataItem, _ = response["data"].(map[string]interface{})
+	assert.Equal(t, float64(isaacAsimov.ID), dataItem["id"])
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/authors/Unknown", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestETag(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	DB.AutoMigrate(&Author{})
+	RegisterModel(router, Book{}, "books", &ApiConfig{ETag: &ETagConfig{}})
+	RegisterModel(router, Author{}, "authors", &ApiConfig{ETag: &ETagConfig{Field: "ID", RequireIfMatch: true}})
+
+	isaacAsimov := Author{Name: stringPtr("Isaac Asimov")}
+	DB.Create(&isaacAsimov)
+	nightfall := Book{Title: stringPtr("Nightfall"), AuthorID: isaacAsimov.ID, Pages: intPtr(501)}
+	DB.Create(&nightfall)
+
+	// Test item not modified
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v", nightfall.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v", nightfall.ID), nil)
+	req.Header.Set("If-None-Match", etag)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	// Test list not modified
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	listETag := w.Header().Get("ETag")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books", nil)
+	req.Header.Set("If-None-Match", listETag)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	// Test update with a stale tag
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/books/%v", nightfall.ID), bytes.NewBufferString(`{"pages": 10}`))
+	req.Header.Set("If-Match", etag)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/books/%v", nightfall.ID), bytes.NewBufferString(`{"pages": 20}`))
+	req.Header.Set("If-Match", etag)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	var book Book
+	DB.First(&book, nightfall.ID)
+	assert.Equal(t, 10, *book.Pages)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books", nil)
+	req.Header.Set("If-None-Match", listETag)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Test If-Match checked again on the row locked by the update
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v", nightfall.ID), nil)
+	router.ServeHTTP(w, req)
+	etag = w.Header().Get("ETag")
+
+	concurrent := true
+	DB.Callback().Query().After("gorm:query").Register("concurrent_update", func(tx *gorm.DB) {
+		if concurrent {
+			concurrent = false
+			tx.Session(&gorm.Session{NewDB: true}).Model(&Book{}).Where("id = ?", nightfall.ID).Update("pages", 30)
+		}
+	})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/books/%v", nightfall.ID), bytes.NewBufferString(`{"pages": 20}`))
+	req.Header.Set("If-Match", etag)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	DB.Callback().Query().Remove("concurrent_update")
+
+	DB.First(&book, nightfall.ID)
+	assert.Equal(t, 30, *book.Pages)
+
+	// Test required If-Match with a version field
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("/authors/%v", isaacAsimov.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+
+	DB.Delete(&book)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("/authors/%v", isaacAsimov.ID), nil)
+	req.Header.Set("If-Match", fmt.Sprintf(`"%v"`, isaacAsimov.ID))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestOptimisticLocking(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Document{})
+	RegisterModel(router, Document{}, "documents", &ApiConfig{})
+
+	// Test the version starts at 1
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/documents", bytes.NewBufferString(`{"title": "Draft", "version": 7}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var document Document
+	DB.First(&document)
+	assert.Equal(t, uint64(1), document.Version)
+
+	// Test updates increment the version
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/documents/%v", document.ID), bytes.NewBufferString(`{"title": "Review", "version": 1}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/documents/%v", document.ID), bytes.NewBufferString(`{"title": "Final"}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	DB.First(&document, document.ID)
+	assert.Equal(t, uint64(3), document.Version)
+
+	// Test stale version
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/documents/%v", document.ID), bytes.NewBufferString(`{"title": "Stale", "version": 1}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	errors, _ := response["errors"].([]interface{})
+	current, _ := errors[0].(map[string]interface{})["details"].(map[string]interface{})
+	assert.Equal(t, "Final", current["title"])
+	assert.Equal(t, float64(3), current["version"])
+
+	DB.First(&document, document.ID)
+	assert.Equal(t, "Final", *document.Title)
+}
+
+func TestCache(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	books := RegisterModel(router, Book{}, "books", &ApiConfig{Cache: &CacheConfig{Store: NewMemoryCache(10), TTL: time.Minute}})
+
+	nightfall := Book{Title: stringPtr("Nightfall"), AuthorID: 1, Pages: intPtr(501)}
+	DB.Create(&nightfall)
+
+	// Test cached list, keyed by the normalized query
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/books?fields=title&limit=10", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+
+	DB.Model(&nightfall).Update("title", "Changed outside")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books?limit=10&fields=title", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	dataItems, _ := response["data"].([]interface{})
+	assert.Equal(t, "Nightfall", dataItems[0].(map[string]interface{})["title"])
+
+	// Test invalidation by the writes of the resource
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/books/%v", nightfall.ID), bytes.NewBufferString(`{"title": "Nightfall"}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v", nightfall.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v", nightfall.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+
+	// Test errors are not cached
+	for i := 0; i < 2; i++ {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/books/999", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	}
+
+	// Test manual invalidation
+	books.InvalidateCache(context.Background())
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v", nightfall.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+
+	// Test LRU eviction
+	cache := NewMemoryCache(2)
+	cache.Set(ctx, "a", []byte("1"), 0)
+	cache.Set(ctx, "b", []byte("2"), 0)
+	cache.Get(ctx, "a")
+	cache.Set(ctx, "c", []byte("3"), 0)
+	_, ok, _ := cache.Get(ctx, "b")
+	assert.False(t, ok)
+	value, ok, _ := cache.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", string(value))
+
+	// Test Vary required with a Permission
+	assert.Panics(t, func() {
+		RegisterModel(SetupRouter(), Book{}, "books", &ApiConfig{Permission: AuthorBooksPermission{}, Cache: &CacheConfig{}})
+	})
+
+	// Test responses keyed by the Vary of the HTTP cache
+	router = SetupRouter()
+	RegisterModel(router, Book{}, "books", &ApiConfig{
+		Permission: AuthorBooksPermission{},
+		Cache:      &CacheConfig{Store: NewMemoryCache(10), Vary: func(c *gin.Context) string { return "" }},
+		HTTPCache:  &HTTPCacheConfig{Vary: []string{"X-Author"}},
+	})
+
+	for _, author := range []uint64{1, 2} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v", nightfall.ID), nil)
+		req.Header.Set("X-Author", fmt.Sprint(author))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	}
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Test nested lists not cached, the parent being looked up by each request
+	DB.AutoMigrate(&Author{})
+	router = SetupRouter()
+	authors := RegisterModel(router, Author{}, "authors", nil)
+	RegisterNested(authors, RegisterModel(router, Book{}, "books", &ApiConfig{Cache: &CacheConfig{Store: NewMemoryCache(10)}}), "Books")
+
+	author := Author{Name: stringPtr("Isaac Asimov")}
+	DB.Create(&author)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/authors/%v/books", author.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-Cache"))
+
+	DB.Delete(&author)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/authors/%v/books", author.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHTTPCache(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	RegisterModel(router, Book{}, "books", &ApiConfig{HTTPCache: &HTTPCacheConfig{
+		CacheControl: "public, max-age=300",
+		Vary:         []string{"Authorization"},
+	}})
+
+	nightfall := Book{Title: stringPtr("Nightfall"), AuthorID: 1, Pages: intPtr(501)}
+	DB.Create(&nightfall)
+	DB.Model(&nightfall).UpdateColumn("updated_at", 1700000000)
+
+	// Test the cache headers of the item
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v", nightfall.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "public, max-age=300", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "Accept, Authorization", w.Header().Get("Vary"))
+	assert.Equal(t, time.Unix(1700000000, 0).UTC().Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+
+	// Test not modified since
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v", nightfall.ID), nil)
+	req.Header.Set("If-Modified-Since", time.Unix(1700000000, 0).UTC().Format(http.TimeFormat))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	// Test modified since
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v", nightfall.ID), nil)
+	req.Header.Set("If-Modified-Since", time.Unix(1600000000, 0).UTC().Format(http.TimeFormat))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Test lists without Last-Modified, the deleted rows don't change it
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/books", nil)
+	req.Header.Set("If-Modified-Since", time.Unix(1700000000, 0).UTC().Format(http.TimeFormat))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "public, max-age=300", w.Header().Get("Cache-Control"))
+	assert.Empty(t, w.Header().Get("Last-Modified"))
+}
+
+func TestAudit(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	DB.AutoMigrate(&AuditEntry{})
+	RegisterModel(router, Book{}, "books", &ApiConfig{Audit: &Audit{
+		Actor: func(c *gin.Context) string { return c.GetHeader("X-User") },
+	}})
+
+	// Test create, update and delete are recorded
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/books", bytes.NewBufferString(`{"title": "Nightfall", "author_id": 1}`))
+	req.Header.Set("X-User", "ann")
+	req.Header.Set("X-Request-ID", "req-1")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var book Book
+	DB.First(&book)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/books/%v", book.ID), bytes.NewBufferString(`{"title": "Foundation", "pages": 255}`))
+	req.Header.Set("X-User", "bob")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Test history
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v/history", book.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	dataItems, _ := response["data"].([]interface{})
+	assert.Len(t, dataItems, 2)
+
+	created := dataItems[0].(map[string]interface{})
+	assert.Equal(t, "create", created["action"])
+	assert.Equal(t, "ann", created["actor"])
+	assert.Equal(t, "req-1", created["request_id"])
+	assert.Equal(t, fmt.Sprint(book.ID), created["item_id"])
+
+	updated := dataItems[1].(map[string]interface{})
+	assert.Equal(t, "update", updated["action"])
+	assert.Equal(t, "bob", updated["actor"])
+	changes := updated["changes"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"before": "Nightfall", "after": "Foundation"}, changes["title"])
+	assert.Equal(t, map[string]interface{}{"before": nil, "after": float64(255)}, changes["pages"])
+	assert.NotContains(t, changes, "author_id")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("/books/%v", book.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	var entry AuditEntry
+	DB.Last(&entry)
+	assert.Equal(t, ActionDelete, entry.Action)
+	assert.Equal(t, fmt.Sprint(book.ID), entry.ItemID)
+
+	// Test history of the deleted item
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v/history", book.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response = map[string]interface{}{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	dataItems = response["data"].([]interface{})
+	assert.Len(t, dataItems, 3)
+	assert.Equal(t, "delete", dataItems[2].(map[string]interface{})["action"])
+
+	// Test history of the deleted item hidden with a Permission, which can't check it anymore
+	permitted := SetupRouter()
+	RegisterModel(permitted, Book{}, "books", &ApiConfig{Permission: AuthorBooksPermission{}, Audit: &Audit{}})
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/books/%v/history", book.ID), nil)
+	req.Header.Set("X-Author", "1")
+	permitted.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Test failed writes are not recorded
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/books", bytes.NewBufferString(`{"title": "No author"}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var count int64
+	DB.Model(&AuditEntry{}).Count(&count)
+	assert.Equal(t, int64(3), count)
+}
+
+func TestOutbox(t *testing.T) {
+	router, ctx, db, container := initializeTestDatabase(t)
+	defer db.Close()
+	defer container.Terminate(ctx)
+
+	DB.AutoMigrate(&Book{})
+	DB.AutoMigrate(&OutboxEvent{})
+	RegisterModel(router, Book{}, "books", &ApiConfig{Outbox: &OutboxConfig{}})
+
+	// Test the writes record their events
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/books", bytes.NewBufferString(`{"title": "Nightfall", "author_id": 1}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var book Book
+	DB.First(&book)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPut, fmt.Sprintf("/books/%v", book.ID), bytes.NewBufferString(`{"pages": 255}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/books", bytes.NewBufferString(`{"title": "Fight Club", "author_id": 2}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/books", bytes.NewBufferString(`{"title": "No author"}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var count int64
+	DB.Model(&OutboxEvent{}).Count(&count)
+	assert.Equal(t, int64(3), count)
+
+	// Test the events of an item wait for its failed ones
+	failures := 1
+	published := []string{}
+	dispatcher := &Dispatcher{
+		Publisher: PublisherFunc(func(ctx context.Context, event *OutboxEvent) error {
+			if event.ItemID == fmt.Sprint(book.ID) && failures > 0 {
+				failures--
+				return fmt.Errorf("unavailable")
+			}
+			published = append(published, event.Topic)
+			return nil
+		}),
+		Backoff: func(attempts int) time.Duration { return 0 },
+	}
+
+	n, err := dispatcher.Dispatch(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"book.created"}, published)
+
+	n, err = dispatcher.Dispatch(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"book.created", "book.created", "book.updated"}, published)
+
+	var event OutboxEvent
+	DB.First(&event)
+	assert.Equal(t, 2, event.Attempts)
+	assert.Equal(t, "unavailable", event.LastError)
+	assert.NotNil(t, event.PublishedAt)
+
+	// Test the channel publisher
+	events := make(chan OutboxEvent, 1)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodDelete, fmt.Sprintf("/books/%v", book.ID), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	n, err = (&Dispatcher{Publisher: ChannelPublisher(events)}).Dispatch(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	deleted := <-events
+	assert.Equal(t, "book.deleted", deleted.Topic)
+	assert.Contains(t, string(deleted.Payload), "Nightfall")
+
+	// Test the items waiting for a retry don't hold the others, and the failed events
+	for i := 0; i < 3; i++ {
+		DB.Create(&OutboxEvent{Topic: "book.updated", Entity: "book", ItemID: "failing"})
+	}
+	DB.Create(&OutboxEvent{Topic: "book.updated", Entity: "book", ItemID: "working"})
+
+	published = []string{}
+	reported := []error{}
+	dispatcher = &Dispatcher{
+		Publisher: PublisherFunc(func(ctx context.Context, event *OutboxEvent) error {
+			if event.ItemID == "failing" {
+				return fmt.Errorf("unavailable")
+			}
+			published = append(published, event.ItemID)
+			return nil
+		}),
+		BatchSize:   3,
+		Backoff:     func(attempts int) time.Duration { return time.Hour },
+		MaxAttempts: 2,
+		OnError:     func(err error) { reported = append(reported, err) },
+	}
+
+	n, err = dispatcher.Dispatch(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	n, err = dispatcher.Dispatch(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"working"}, published)
+	assert.Len(t, reported, 1)
+
+	DB.Model(&OutboxEvent{}).Where("item_id = ?", "failing").Update("next_attempt_at", nil)
+	n, err = dispatcher.Dispatch(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+
+	DB.Model(&OutboxEvent{}).Where("failed_at IS NOT NULL").Count(&count)
+	assert.Equal(t, int64(1), count)
+}
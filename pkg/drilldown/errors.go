@@ -0,0 +1,158 @@
+package drilldown
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-sql-driver/mysql"
+	"github.com/iancoleman/strcase"
+	"gorm.io/gorm"
+)
+
+// HTTPError is an error that is sent to the client with the given status code.
+// Code is a stable identifier for clients to match on, Field the input the error
+// refers to, if any, and Details any additional data
+type HTTPError struct {
+	Status  int         `json:"-"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Field   string      `json:"field,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// NewHTTPError returns an error with a code derived from the status, ex: not_found
+func NewHTTPError(status int, message string) *HTTPError {
+	return &HTTPError{Status: status, Code: strcase.ToSnake(http.StatusText(status)), Message: message}
+}
+
+// NewFieldError returns an error about a field of the input
+func NewFieldError(status int, code string, field string, message string) *HTTPError {
+	return &HTTPError{Status: status, Code: code, Field: field, Message: message}
+}
+
+func (e *HTTPError) Error() string {
+	return e.Message
+}
+
+// Errors are sent together in a single response, with the status code of the first one
+type Errors []*HTTPError
+
+func (e Errors) Error() string {
+	messages := []string{}
+	for _, err := range e {
+		messages = append(messages, err.Message)
+	}
+
+	return strings.Join(messages, ", ")
+}
+
+// ErrorFormatter writes the response of a failed request
+type ErrorFormatter func(c *gin.Context, status int, errs []*HTTPError)
+
+// JSONErrors is the default ErrorFormatter: {"errors": [{"code": ..., "message": ...}]}
+func JSONErrors(c *gin.Context, status int, errs []*HTTPError) {
+	c.JSON(status, gin.H{"errors": errs})
+}
+
+// ProblemJSON is an ErrorFormatter writing RFC 7807 application/problem+json responses,
+// the errors are included in the errors extension member
+func ProblemJSON(c *gin.Context, status int, errs []*HTTPError) {
+	problem := gin.H{
+		"type":   "about:blank",
+		"title":  http.StatusText(status),
+		"status": status,
+		"errors": errs,
+	}
+	if len(errs) == 1 {
+		problem["detail"] = errs[0].Message
+	}
+
+	c.Header("Content-Type", "application/problem+json")
+	c.JSON(status, problem)
+}
+
+var (
+	errNotFound      = NewHTTPError(http.StatusNotFound, "Record not found!")
+	errInternalError = &HTTPError{Status: http.StatusInternalServerError, Code: "internal_error", Message: "Internal server error"}
+	errInvalidBody   = &HTTPError{Status: http.StatusBadRequest, Code: "invalid_body", Message: "Invalid JSON body"}
+	errNotAnObject   = &HTTPError{Status: http.StatusBadRequest, Code: "invalid_body", Message: "The body must be a JSON object"}
+)
+
+// httpErrors converts the error to the ones sent to the client, HTTPErrors keep their
+// status code, validation, malformed body and database errors are reported
+// as bad requests and everything else as a server error
+func httpErrors(err error) (int, []*HTTPError) {
+	var errs Errors
+	if errors.As(err, &errs) && len(errs) > 0 {
+		return errs[0].Status, errs
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Status, []*HTTPError{httpErr}
+	}
+
+	var ve validator.ValidationErrors
+	if errors.As(err, &ve) {
+		errs := []*HTTPError{}
+		for _, e := range ve {
+			err := validationError(e.Field(), e.ActualTag(), validationMessage(e))
+			if e.Param() != "" {
+				err.Details.(gin.H)["param"] = e.Param()
+			}
+			errs = append(errs, err)
+		}
+		return http.StatusBadRequest, errs
+	}
+
+	var se *json.SyntaxError
+	var te *json.UnmarshalTypeError
+	if errors.As(err, &se) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return http.StatusBadRequest, []*HTTPError{errInvalidBody}
+	}
+	if errors.As(err, &te) {
+		return http.StatusBadRequest, []*HTTPError{{
+			Status:  http.StatusBadRequest,
+			Code:    "invalid_type",
+			Message: fmt.Sprintf("%v expects a %v, received: %v", te.Field, te.Type, te.Value),
+			Field:   te.Field,
+		}}
+	}
+
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		return http.StatusBadRequest, []*HTTPError{{
+			Status:  http.StatusBadRequest,
+			Code:    "database_error",
+			Message: fmt.Sprintf("Error %v: %v", me.Number, me.Message),
+			Details: gin.H{"number": me.Number},
+		}}
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound, []*HTTPError{errNotFound}
+	}
+
+	return http.StatusInternalServerError, []*HTTPError{errInternalError}
+}
+
+// abortWithError sends the error to the client with the formatter of the resource,
+// and records it in c.Errors for the middlewares
+func abortWithError(c *gin.Context, config *ApiConfig, err error) {
+	c.Error(err)
+	status, errs := httpErrors(err)
+
+	format := JSONErrors
+	if config != nil && config.ErrorFormatter != nil {
+		format = config.ErrorFormatter
+	}
+
+	format(c, status, errs)
+	c.Abort()
+}
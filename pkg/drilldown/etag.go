@@ -0,0 +1,137 @@
+package drilldown
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ETagConfig enables the entity tags of the list and item responses: the reads with a
+// matching If-None-Match respond 304 and the updates and deletes with an If-Match
+// that doesn't match the item respond 412
+type ETagConfig struct {
+	// Field holding the version of the item, ex: Version or UpdatedAt,
+	// the tag is the hash of the item when empty
+	Field string
+	// RequireIfMatch rejects the updates and deletes without If-Match with 428
+	RequireIfMatch bool
+}
+
+var errPreconditionFailed = NewHTTPError(http.StatusPreconditionFailed, "The item has been modified")
+var errPreconditionRequired = NewHTTPError(http.StatusPreconditionRequired, "If-Match required")
+
+// hashETag returns the tag of the value serialized to JSON
+func hashETag(v interface{}) (string, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(encoded)
+	return fmt.Sprintf(`"%x"`, sum[:16]), nil
+}
+
+// etag returns the tag of the item, its version when the config has a Field, empty without ETags
+func (res *Resource[M]) etag(c *gin.Context, item *M) (string, error) {
+	if res.config.ETag == nil {
+		return "", nil
+	}
+
+	if res.config.ETag.Field != "" {
+		s, err := res.schema()
+		if err != nil {
+			return "", err
+		}
+
+		f := s.LookUpField(res.config.ETag.Field)
+		if f == nil {
+			return "", fmt.Errorf("invalid ETag field %v on %v", res.config.ETag.Field, s.Name)
+		}
+
+		if value, zero := f.ValueOf(c, reflect.ValueOf(item)); !zero {
+			version := reflect.Indirect(reflect.ValueOf(value)).Interface()
+			if t, ok := version.(time.Time); ok {
+				version = t.UnixNano()
+			}
+			return fmt.Sprintf(`"%v"`, version), nil
+		}
+	}
+
+	return hashETag((&Response{Data: item}).Body())
+}
+
+// matchETag reports if the tag is in the list of the header, weak compares the tags ignoring the W/ prefix
+func matchETag(header string, etag string, weak bool) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" {
+			return true
+		}
+		if weak {
+			t = strings.TrimPrefix(t, "W/")
+			etag = strings.TrimPrefix(etag, "W/")
+		} else if strings.HasPrefix(t, "W/") {
+			continue
+		}
+		if t == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkIfMatch rejects the writes of the item when the If-Match of the request doesn't match it
+func (res *Resource[M]) checkIfMatch(c *gin.Context, item *M) error {
+	if res.config.ETag == nil {
+		return nil
+	}
+
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		if res.config.ETag.RequireIfMatch {
+			return errPreconditionRequired
+		}
+		return nil
+	}
+
+	etag, err := res.etag(c, item)
+	if err != nil {
+		return err
+	}
+
+	if !matchETag(header, etag, false) {
+		return errPreconditionFailed
+	}
+
+	return nil
+}
+
+// lockIfMatch locks the row of the item in the transaction of the write and checks the If-Match
+// of the request again against it, so two writes with the same tag can't both pass
+func (res *Resource[M]) lockIfMatch(c *gin.Context, tx *gorm.DB, method string, item *M) error {
+	if res.config.ETag == nil || c.GetHeader("If-Match") == "" {
+		return nil
+	}
+
+	var current M
+	err := res.mutation(c, tx.Session(&gorm.Session{NewDB: true}), method, item).
+		Clauses(clause.Locking{Strength: "UPDATE"}).First(&current).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errPreconditionFailed
+	}
+	if err != nil {
+		return err
+	}
+
+	return res.checkIfMatch(c, &current)
+}
@@ -0,0 +1,477 @@
+package drilldown
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iancoleman/strcase"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (res *Resource[M]) list(c *gin.Context) {
+	res.listWith(c, nil)
+}
+
+// listWith runs the list pipeline with an optional extra constraint on the query
+func (res *Resource[M]) listWith(c *gin.Context, constraint func(db *gorm.DB) *gorm.DB) {
+	if err := checkPermission(c, res.config, ActionList); err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	qmap := c.Request.URL.Query()
+
+	var q *gorm.DB
+	if IsTestRun() {
+		q = database(c, res.config).Debug().Table(res.name)
+	} else {
+		q = database(c, res.config).Table(res.name)
+	}
+
+	if len(res.config.ScopesFind) > 0 {
+		q = q.WithContext(c).Scopes(res.config.ScopesFind...)
+	}
+	q = filterRows(c, res.config, q)
+	if constraint != nil {
+		q = constraint(q)
+	}
+
+	q, filters, errors := applyScopes(q, res.config.Scopes, qmap)
+	if len(errors) > 0 {
+		abortWithError(c, res.config, errors)
+		return
+	}
+
+	selectChan := make(chan Select)
+	condChan := make(chan Condition)
+	orderChan := make(chan []OrderBy)
+
+	fp := qmap.Get("fields")
+	go prepareSelectFields(fp, selectChan)
+	go prepareCondition(res.name, filters, condChan)
+	orderBy := qmap.Get("order")
+	go prepareOrderBy(orderBy, orderChan)
+
+	if len(errors) > 0 {
+		abortWithError(c, res.config, errors)
+		return
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case sel := <-selectChan:
+			// JOINS
+			if len(sel.Joins) > 0 {
+				for _, j := range sel.Joins {
+					q = q.Joins(j)
+				}
+			}
+
+			// SELECT
+			if len(sel.Fields) > 0 {
+				preparedFields := []string{}
+				v := reflect.ValueOf(res.model)
+				for _, f := range sel.Fields {
+					if !strings.Contains(f, ".") && !v.FieldByName(strcase.ToCamel(f)).IsValid() {
+						errors = append(errors, NewFieldError(http.StatusBadRequest, "invalid_field", f, fmt.Sprintf("Invalid field on the fields selector: %v", f)))
+					} else {
+						preparedFields = append(preparedFields, f)
+					}
+				}
+
+				if len(errors) > 0 {
+					abortWithError(c, res.config, errors)
+					return
+				}
+
+				preparedFields = append(preparedFields, fmt.Sprintf("`%v`.id", res.name))
+				q = q.Select(preparedFields)
+			}
+		case cond := <-condChan:
+			if len(cond.Joins) > 0 {
+				for _, j := range cond.Joins {
+					q = q.Joins(j)
+				}
+			}
+
+			if len(cond.Values) > 0 {
+				var vs []interface{}
+
+				for _, f := range cond.Fields {
+					v := reflect.ValueOf(res.model)
+					// Check if field exists in the model
+					if !strings.Contains(f, ".") && !v.FieldByName(strcase.ToCamel(f)).IsValid() {
+						errors = append(errors, NewFieldError(http.StatusBadRequest, "invalid_field", f, fmt.Sprintf("Invalid field on the condition: %v", f)))
+					}
+				}
+
+				if len(errors) > 0 {
+					abortWithError(c, res.config, errors)
+					return
+				}
+
+				for _, v := range cond.Values {
+					vs = append(vs, v)
+				}
+				q = q.Where(cond.Where, vs...)
+			}
+		case ov := <-orderChan:
+			for _, o := range ov {
+				v := reflect.ValueOf(res.model)
+				// Check if field exists in the model
+				if !strings.Contains(o.Field, ".") && !v.FieldByName(strcase.ToCamel(o.Field)).IsValid() {
+					errors = append(errors, NewFieldError(http.StatusBadRequest, "invalid_field", o.Field, fmt.Sprintf("Invalid field on the order by: %v", o.Field)))
+					continue
+				}
+
+				q = q.Order(fmt.Sprintf("`%v` %v", o.Field, o.Modifier))
+			}
+
+			if len(errors) > 0 {
+				abortWithError(c, res.config, errors)
+				return
+			}
+		}
+	}
+
+	// LIMIT
+	limit := qmap.Get("limit")
+	if limit != "" {
+		limitI, err := strconv.Atoi(limit)
+		if err != nil {
+			errors = append(errors, NewFieldError(http.StatusBadRequest, "invalid_parameter", "limit", fmt.Sprintf("Limit expects a number, received: %v", limit)))
+		} else {
+			q = q.Limit(limitI)
+		}
+	}
+
+	if len(errors) > 0 {
+		abortWithError(c, res.config, errors)
+		return
+	}
+
+	// OFFSET
+	offset := qmap.Get("offset")
+	if offset != "" {
+		offsetI, err := strconv.Atoi(offset)
+		if err != nil {
+			errors = append(errors, NewFieldError(http.StatusBadRequest, "invalid_parameter", "offset", fmt.Sprintf("Offset expects a number, received: %v", offset)))
+		} else {
+			if limit == "" {
+				q = q.Limit(20) // Default pagination to 20
+			}
+			q = q.Offset(offsetI)
+		}
+	}
+
+	// SEARCH
+	if search := qmap.Get("search"); search != "" {
+		if res.config.Search == nil || len(res.config.Search.Fields) == 0 {
+			errors = append(errors, NewFieldError(http.StatusBadRequest, "invalid_parameter", "search", "Search is not enabled"))
+		} else {
+			q = q.Scopes(searchScope(res.name, res.config.Search, search, res.config.Search.Rank && orderBy == ""))
+		}
+	}
+
+	// STREAM
+	stream := false
+	if s := qmap.Get("stream"); s != "" {
+		streamB, err := strconv.ParseBool(s)
+		if err != nil {
+			errors = append(errors, NewFieldError(http.StatusBadRequest, "invalid_parameter", "stream", fmt.Sprintf("Stream expects a boolean, received: %v", s)))
+		}
+		stream = streamB
+	}
+
+	if len(errors) > 0 {
+		abortWithError(c, res.config, errors)
+		return
+	}
+
+	if err := runHook(res.config.BeforeList, c, q, nil); err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	if stream && res.stream(c, q) {
+		return
+	}
+
+	rows, err := q.Rows()
+	if err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+	defer rows.Close()
+
+	items, related, err := res.scanRows(rows)
+	if err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	if err := runHook(res.config.AfterList, c, q, &items); err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	data := res.project(items, related, qmap.Get("fields"))
+	res.respond(c, http.StatusOK, &Response{Data: data, Errors: errors, List: true})
+}
+
+func (res *Resource[M]) retrieve(c *gin.Context) {
+	err, item, _, _ := lookupItem[M](c, res.config, http.MethodGet, ActionRetrieve, res.config.BeforeRead)
+	if err != nil {
+		return
+	}
+
+	if err := runHook(res.config.AfterRead, c, database(c, res.config).WithContext(c), item); err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	etag, err := res.etag(c, item)
+	if err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	lastModified, err := res.lastModified(c, item)
+	if err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	res.respond(c, http.StatusOK, &Response{Data: item, etag: etag, lastModified: lastModified})
+}
+
+func (res *Resource[M]) create(c *gin.Context) {
+	res.createWith(c, nil)
+}
+
+// createWith creates the item calling prepare before and after binding the body,
+// so the fields it sets are both considered by the validation and not overridable.
+// The tenant of the request and the initial version are set the same way
+func (res *Resource[M]) createWith(c *gin.Context, prepare func(item *M)) {
+	if err := checkPermission(c, res.config, ActionCreate); err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	var input M
+	setup := func() error {
+		if prepare != nil {
+			prepare(&input)
+		}
+		if err := res.setVersion(c, &input); err != nil {
+			return err
+		}
+		return res.setTenant(c, &input)
+	}
+
+	if err := setup(); err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	if err := bindObject(c, &input); err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	if err := setup(); err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	if err := checkObjectPermission(c, res.config, ActionCreate, &input); err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	err := database(c, res.config).WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := runHook(res.config.BeforeCreate, c, tx, &input); err != nil {
+			return err
+		}
+
+		if err := runValidators(c, res.config, tx, &input); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&input).Error; err != nil {
+			return err
+		}
+
+		if err := res.audit(c, tx, ActionCreate, nil, &input); err != nil {
+			return err
+		}
+
+		if err := res.enqueue(c, tx, ActionCreate, &input); err != nil {
+			return err
+		}
+
+		return runHook(res.config.AfterCreate, c, tx, &input)
+	})
+
+	if err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": input, "errors": []string{}})
+}
+
+func (res *Resource[M]) update(c *gin.Context) {
+	var input M
+	err, item, _, _ := GetItem[M](c, res.config, "PUT")
+	if err != nil {
+		return
+	}
+
+	if err := res.checkIfMatch(c, item); err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	if err := bindPartial(c, &input); err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	if err := res.checkTenant(c, &input); err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	version, err := res.lockVersion(c, item, &input)
+	if err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	before, err := res.snapshot(item)
+	if err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	err = database(c, res.config).WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := res.lockIfMatch(c, tx, http.MethodPut, item); err != nil {
+			return err
+		}
+
+		if err := runHook(res.config.BeforeUpdate, c, tx, &input); err != nil {
+			return err
+		}
+
+		if len(res.config.Validators) > 0 {
+			candidate, err := res.merged(c, item, &input)
+			if err != nil {
+				return err
+			}
+			if err := runValidators(c, res.config, tx, candidate); err != nil {
+				return err
+			}
+		}
+
+		if err := res.lockedUpdate(c, tx, item, &input, version); err != nil {
+			return err
+		}
+
+		if err := res.audit(c, tx, ActionUpdate, before, item); err != nil {
+			return err
+		}
+
+		if err := res.enqueue(c, tx, ActionUpdate, item); err != nil {
+			return err
+		}
+
+		return runHook(res.config.AfterUpdate, c, tx, item)
+	})
+
+	if err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (res *Resource[M]) delete(c *gin.Context) {
+	err, item, _, _ := GetItem[M](c, res.config, "DELETE")
+	if err != nil {
+		return
+	}
+
+	if err := res.checkIfMatch(c, item); err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	before, err := res.snapshot(item)
+	if err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	err = database(c, res.config).WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := res.lockIfMatch(c, tx, http.MethodDelete, item); err != nil {
+			return err
+		}
+
+		if err := runHook(res.config.BeforeDelete, c, tx, item); err != nil {
+			return err
+		}
+
+		result := res.mutation(c, tx, http.MethodDelete, item).Delete(item)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errNotFound
+		}
+
+		if err := res.audit(c, tx, ActionDelete, before, item); err != nil {
+			return err
+		}
+
+		if err := res.enqueue(c, tx, ActionDelete, item); err != nil {
+			return err
+		}
+
+		return runHook(res.config.AfterDelete, c, tx, item)
+	})
+
+	if err != nil {
+		abortWithError(c, res.config, err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// mutation restricts the statement writing the item of the request to its row, with the
+// scopes of the method and the permission filter, so it can't reach the rows hidden from it
+func (res *Resource[M]) mutation(c *gin.Context, tx *gorm.DB, method string, item *M) *gorm.DB {
+	s, err := res.schema()
+	if err != nil {
+		tx.AddError(err)
+		return tx
+	}
+
+	q := tx
+	for _, f := range s.PrimaryFields {
+		value, _ := f.ValueOf(c, reflect.ValueOf(item))
+		q = q.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: f.DBName}, Value: value})
+	}
+	if scopes := res.config.scopes(method); len(scopes) > 0 {
+		q = q.Scopes(scopes...)
+	}
+
+	return filterRows(c, res.config, q)
+}
@@ -0,0 +1,24 @@
+package drilldown
+
+import (
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Hook is called by the generated handlers around each operation.
+// It receives the request context, the current transaction (all the writes of a
+// request run inside a single transaction) and a pointer to the item, or to the
+// results for the list hooks, which can be mutated in place.
+// BeforeList and BeforeRead run once the permission is checked and receive the lookup
+// query instead, with a nil item, the conditions they add to it restrict the rows found.
+// Returning an error aborts the request and rolls back the transaction,
+// use NewHTTPError to choose the status code sent to the client
+type Hook func(c *gin.Context, tx *gorm.DB, item interface{}) error
+
+func runHook(hook Hook, c *gin.Context, tx *gorm.DB, item interface{}) error {
+	if hook == nil {
+		return nil
+	}
+
+	return hook(c, tx, item)
+}
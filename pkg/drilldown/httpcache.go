@@ -0,0 +1,157 @@
+package drilldown
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/schema"
+)
+
+// HTTPCacheConfig sets the cache headers of the list and item responses, so the clients
+// and the CDNs in front of the API can cache them. The item reads with an If-Modified-Since
+// not older than the Last-Modified of the response respond 304
+type HTTPCacheConfig struct {
+	// CacheControl of the responses, ex: "public, max-age=300"
+	CacheControl string
+	// LastModifiedField holds the time of the last change of the items, UpdatedAt when empty.
+	// Set it to "-" to not send Last-Modified. The lists don't have it, as the times of
+	// their rows don't tell when one was deleted, use the ETag to revalidate them
+	LastModifiedField string
+	// Vary lists the request headers changing the responses, besides Accept, ex: Authorization
+	Vary []string
+}
+
+// notModified evaluates the conditional headers of the request against the validators of
+// the response, If-Modified-Since is ignored when the request has If-None-Match
+func notModified(c *gin.Context, header http.Header) bool {
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		etag := header.Get("ETag")
+		return etag != "" && matchETag(inm, etag, true)
+	}
+
+	if ims := c.GetHeader("If-Modified-Since"); ims != "" {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		modified, err := http.ParseTime(header.Get("Last-Modified"))
+		if err != nil {
+			return false
+		}
+
+		return !modified.After(since)
+	}
+
+	return false
+}
+
+// cacheHeaders sets the validators and the cache headers of the successful reads
+func (res *Resource[M]) cacheHeaders(c *gin.Context, response *Response) error {
+	vary := []string{}
+
+	if res.config.ETag != nil {
+		etag := response.etag
+		if etag == "" {
+			var err error
+			if etag, err = hashETag(response.Body()); err != nil {
+				return err
+			}
+		}
+
+		c.Header("ETag", etag)
+		vary = append(vary, "Accept")
+	}
+
+	if config := res.config.HTTPCache; config != nil {
+		if len(vary) == 0 {
+			vary = append(vary, "Accept")
+		}
+		vary = append(vary, config.Vary...)
+
+		if config.CacheControl != "" {
+			c.Header("Cache-Control", config.CacheControl)
+		}
+		if !response.lastModified.IsZero() {
+			c.Header("Last-Modified", response.lastModified.UTC().Format(http.TimeFormat))
+		}
+	}
+
+	if len(vary) > 0 {
+		c.Header("Vary", strings.Join(vary, ", "))
+	}
+
+	return nil
+}
+
+// lastModifiedField returns the field with the time of the last change of the items, nil without it
+func (res *Resource[M]) lastModifiedField() (*schema.Field, error) {
+	if res.config.HTTPCache == nil || res.config.HTTPCache.LastModifiedField == "-" {
+		return nil, nil
+	}
+
+	s, err := res.schema()
+	if err != nil {
+		return nil, err
+	}
+
+	if name := res.config.HTTPCache.LastModifiedField; name != "" {
+		field := s.LookUpField(name)
+		if field == nil {
+			return nil, fmt.Errorf("invalid last modified field %v on %v", name, s.Name)
+		}
+		return field, nil
+	}
+
+	return s.LookUpField("UpdatedAt"), nil
+}
+
+// lastModified returns the last change of the item, zero when unknown
+func (res *Resource[M]) lastModified(c *gin.Context, item *M) (time.Time, error) {
+	field, err := res.lastModifiedField()
+	if err != nil || field == nil {
+		return time.Time{}, err
+	}
+
+	value, zero := field.ValueOf(c, reflect.ValueOf(item))
+	if zero {
+		return time.Time{}, nil
+	}
+
+	return timeOf(field, reflect.Indirect(reflect.ValueOf(value))), nil
+}
+
+// timeOf converts the value of the field to a time, the integers are unix
+// timestamps in the unit of its autoUpdateTime, seconds by default
+func timeOf(field *schema.Field, v reflect.Value) time.Time {
+	if t, ok := v.Interface().(time.Time); ok {
+		return t
+	}
+
+	var n int64
+	switch {
+	case v.CanInt():
+		n = v.Int()
+	case v.CanUint():
+		n = int64(v.Uint())
+	default:
+		return time.Time{}
+	}
+
+	unit := field.AutoUpdateTime
+	if unit == 0 {
+		unit = field.AutoCreateTime
+	}
+
+	switch unit {
+	case schema.UnixNanosecond:
+		return time.Unix(0, n)
+	case schema.UnixMillisecond:
+		return time.UnixMilli(n)
+	}
+
+	return time.Unix(n, 0)
+}
@@ -0,0 +1,229 @@
+package drilldown
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var schemaCache = &sync.Map{}
+
+// parseModel returns the schema of the model, with the naming strategy of DB when set
+func parseModel(model interface{}) (*schema.Schema, error) {
+	var namer schema.Namer = schema.NamingStrategy{}
+	if DB != nil {
+		namer = DB.NamingStrategy
+	}
+
+	return schema.Parse(model, schemaCache, namer)
+}
+
+// lookupFields returns the fields in the path of the single items: the ones of the LookupKey,
+// or the LookupField followed by its LookupAlternatives, which share its parameter
+func (config *ApiConfig) lookupFields() []string {
+	if config != nil && len(config.LookupKey) > 0 {
+		return config.LookupKey
+	}
+
+	field := "ID"
+	if config != nil && config.LookupField != "" {
+		field = config.LookupField
+	}
+	if config != nil {
+		return append([]string{field}, config.LookupAlternatives...)
+	}
+
+	return []string{field}
+}
+
+// lookupParams returns the names of the path parameters of the single items
+func lookupParams(s *schema.Schema, config *ApiConfig) []string {
+	fields := config.lookupFields()
+	if config == nil || len(config.LookupKey) == 0 {
+		fields = fields[:1]
+	}
+
+	params := []string{}
+	for _, name := range fields {
+		params = append(params, lookupParam(s, name))
+	}
+
+	return params
+}
+
+func lookupParam(s *schema.Schema, name string) string {
+	if s != nil {
+		if f := s.LookUpField(name); f != nil && f.DBName != "" {
+			return f.DBName
+		}
+	}
+
+	return strings.ToLower(name)
+}
+
+// lookupPath returns the path parameters of the single items of the resource, ex: :author_id/:slug
+func (res *Resource[M]) lookupPath() string {
+	s, _ := res.schema()
+
+	params := []string{}
+	for _, param := range lookupParams(s, res.config) {
+		params = append(params, ":"+param)
+	}
+
+	return strings.Join(params, "/")
+}
+
+// lookupColumn is the column used to look up the single items of the resource,
+// the first one of a composite key
+func (res *Resource[M]) lookupColumn() string {
+	s, _ := res.schema()
+	return lookupParams(s, res.config)[0]
+}
+
+// lookupLink returns the path of the item serialized as the row, false when it misses part of its key
+func (res *Resource[M]) lookupLink(row map[string]interface{}) (string, bool) {
+	s, err := res.schema()
+	if err != nil {
+		return "", false
+	}
+
+	fields := res.config.lookupFields()
+	if len(res.config.LookupKey) == 0 {
+		fields = fields[:1]
+	}
+
+	values := []string{}
+	for _, name := range fields {
+		f := s.LookUpField(name)
+		if f == nil {
+			return "", false
+		}
+		key, ok := jsonName(f.StructField)
+		if !ok {
+			return "", false
+		}
+		value, ok := row[key]
+		if !ok {
+			return "", false
+		}
+		values = append(values, url.PathEscape(fmt.Sprint(value)))
+	}
+
+	return strings.Join(values, "/"), true
+}
+
+// lookupConditions parses the key of the item from the path, returning the conditions
+// to find it for each of the lookup fields, in order, and its legacy representation of GetItem.
+// Values that can't be parsed as any of the lookup fields are reported as bad requests
+func lookupConditions(c *gin.Context, s *schema.Schema, config *ApiConfig) ([]clause.Expression, uint64, *string, error) {
+	var idInt uint64
+	var idString *string
+
+	fields := config.lookupFields()
+	params := lookupParams(s, config)
+
+	if len(params) > 1 {
+		eqs := []clause.Expression{}
+		for i, param := range params {
+			raw := c.Param(param)
+			f := s.LookUpField(fields[i])
+			if f == nil {
+				return nil, idInt, idString, fmt.Errorf("invalid lookup field %v on %v", fields[i], s.Name)
+			}
+
+			value, err := parseKey(f.FieldType, raw)
+			if err != nil {
+				return nil, idInt, idString, NewFieldError(http.StatusBadRequest, "invalid_lookup", param, fmt.Sprintf("Invalid %v: %v", param, raw))
+			}
+			eqs = append(eqs, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: f.DBName}, Value: value})
+		}
+
+		return []clause.Expression{clause.And(eqs...)}, idInt, idString, nil
+	}
+
+	raw := c.Param(params[0])
+	conditions := []clause.Expression{}
+	for i, name := range fields {
+		f := s.LookUpField(name)
+		if f == nil {
+			return nil, idInt, idString, fmt.Errorf("invalid lookup field %v on %v", name, s.Name)
+		}
+
+		value, err := parseKey(f.FieldType, raw)
+		if err != nil {
+			continue
+		}
+
+		if i == 0 {
+			if id, ok := value.(uint64); ok {
+				idInt = id
+			} else {
+				idString = &raw
+			}
+		}
+		conditions = append(conditions, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: f.DBName}, Value: value})
+	}
+
+	if len(conditions) == 0 {
+		return nil, idInt, idString, NewFieldError(http.StatusBadRequest, "invalid_lookup", params[0], fmt.Sprintf("Invalid %v: %v", params[0], raw))
+	}
+
+	return conditions, idInt, idString, nil
+}
+
+// parseKey converts the value of the path to the type of the field, the types
+// implementing encoding.TextUnmarshaler, like uuid.UUID, parse themselves
+func parseKey(t reflect.Type, value string) (interface{}, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	v := reflect.New(t)
+	if u, ok := v.Interface().(encoding.TextUnmarshaler); ok {
+		err := u.UnmarshalText([]byte(value))
+		return v.Elem().Interface(), err
+	}
+
+	e := v.Elem()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, t.Bits())
+		if err != nil {
+			return nil, err
+		}
+		e.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, t.Bits())
+		if err != nil {
+			return nil, err
+		}
+		e.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, t.Bits())
+		if err != nil {
+			return nil, err
+		}
+		e.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, err
+		}
+		e.SetBool(b)
+	case reflect.String:
+		e.SetString(value)
+	default:
+		return nil, fmt.Errorf("unsupported lookup type %v", t)
+	}
+
+	return e.Interface(), nil
+}
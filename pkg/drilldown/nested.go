@@ -0,0 +1,91 @@
+package drilldown
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// RegisterNested exposes the child resource under the items of the parent resource,
+// following the has many (or has one) association of the parent model, ex:
+//
+//	authors := RegisterModel(router, Author{}, "authors", nil)
+//	books := RegisterModel(router, Book{}, "books", nil)
+//	RegisterNested(authors, books, "Books")
+//
+// creates GET /authors/:id/books, with the full list pipeline of the books constrained
+// to the author, and POST /authors/:id/books, setting the author on the new book.
+// It answers 404 when the parent doesn't exist, the nested list is never cached.
+// DB must be set before calling it
+func RegisterNested[P any, M any](parent *Resource[P], child *Resource[M], association string) {
+	stmt := &gorm.Statement{DB: DB}
+	if err := stmt.Parse(new(P)); err != nil {
+		panic(fmt.Sprintf("drilldown: invalid parent model: %v", err))
+	}
+
+	rel, ok := stmt.Schema.Relationships.Relations[association]
+	if !ok || (rel.Type != schema.HasMany && rel.Type != schema.HasOne) {
+		panic(fmt.Sprintf("drilldown: %v is not a has many association of %v", association, stmt.Schema.Name))
+	}
+
+	path := fmt.Sprintf("%v/%v", parent.pathItem, child.name)
+
+	// foreignKeys returns the values of the foreign keys of the child taken from the parent
+	foreignKeys := func(c *gin.Context, item *P) map[*schema.Field]interface{} {
+		values := map[*schema.Field]interface{}{}
+		for _, ref := range rel.References {
+			if ref.OwnPrimaryKey {
+				values[ref.ForeignKey], _ = ref.PrimaryKey.ValueOf(c, reflect.ValueOf(item).Elem())
+			} else {
+				values[ref.ForeignKey] = ref.PrimaryValue
+			}
+		}
+
+		return values
+	}
+
+	// The routes are not cached, a cached list would skip the lookup of the parent,
+	// the creations invalidate the cache of the child themselves
+	uncached := *child.config
+	uncached.Cache = nil
+
+	model := reflect.TypeOf(child.model)
+	routes := mountRoutes(parent.router, &uncached, child.name, []route{
+		{http.MethodGet, path, ActionList, func(c *gin.Context) {
+			err, item, _, _ := getItem[P](c, parent.config, http.MethodGet, ActionRetrieve)
+			if err != nil {
+				return
+			}
+
+			child.listWith(c, func(db *gorm.DB) *gorm.DB {
+				for field, value := range foreignKeys(c, item) {
+					db = db.Where(fmt.Sprintf("`%v`.`%v` = ?", child.name, field.DBName), value)
+				}
+
+				return db
+			})
+		}, model},
+		{http.MethodPost, path, ActionCreate, func(c *gin.Context) {
+			err, item, _, _ := getItem[P](c, parent.config, http.MethodGet, ActionRetrieve)
+			if err != nil {
+				return
+			}
+
+			values := foreignKeys(c, item)
+			child.createWith(c, func(input *M) {
+				for field, value := range values {
+					field.Set(c, reflect.ValueOf(input).Elem(), value)
+				}
+			})
+
+			if child.config.Cache != nil && c.Writer.Status() < http.StatusBadRequest {
+				invalidateCache(c, child.config, child.name)
+			}
+		}, model},
+	})
+	parent.routes = append(parent.routes, routes...)
+}
@@ -0,0 +1,466 @@
+package drilldown
+
+import (
+	"bytes"
+	"database/sql"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iancoleman/strcase"
+	"gorm.io/gorm"
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+// OpenAPIConfig configures the document served by RegisterOpenAPI
+type OpenAPIConfig struct {
+	Title       string
+	Version     string
+	Description string
+	// Path of the document, /openapi.json by default
+	Path string
+	// DocsPath serves a documentation page for the API when set, ex: /docs
+	DocsPath string
+}
+
+// documented is implemented by the registered resources to describe their routes
+type documented interface {
+	document(spec *openAPISpec)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[gin.IRoutes][]documented{}
+)
+
+func register(r gin.IRoutes, d documented) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry[r] = append(registry[r], d)
+}
+
+// RegisterOpenAPI serves the OpenAPI 3.1 document describing the resources registered
+// on the router, and optionally a documentation page, see OpenAPIConfig
+func RegisterOpenAPI(r *gin.Engine, config *OpenAPIConfig) {
+	if config == nil {
+		config = &OpenAPIConfig{}
+	}
+
+	path := config.Path
+	if path == "" {
+		path = "/openapi.json"
+	}
+
+	r.GET(path, func(c *gin.Context) {
+		c.JSON(http.StatusOK, OpenAPI(r, config))
+	})
+
+	if config.DocsPath != "" {
+		docs := template.Must(template.ParseFS(templatesFS, "templates/docs.html"))
+		r.GET(config.DocsPath, func(c *gin.Context) {
+			var page bytes.Buffer
+			if err := docs.Execute(&page, gin.H{"Title": openAPITitle(config), "SpecURL": path}); err != nil {
+				abortWithError(c, nil, err)
+				return
+			}
+
+			c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+		})
+	}
+}
+
+// OpenAPI generates the OpenAPI 3.1 document of the resources registered on the router
+func OpenAPI(r gin.IRoutes, config *OpenAPIConfig) gin.H {
+	if config == nil {
+		config = &OpenAPIConfig{}
+	}
+
+	spec := &openAPISpec{paths: gin.H{}, schemas: gin.H{
+		"Error": gin.H{
+			"type":     "object",
+			"required": []string{"code", "message"},
+			"properties": gin.H{
+				"code":    gin.H{"type": "string"},
+				"message": gin.H{"type": "string"},
+				"field":   gin.H{"type": "string"},
+				"details": gin.H{},
+			},
+		},
+		"Errors": gin.H{
+			"type": "object",
+			"properties": gin.H{
+				"errors": gin.H{"type": "array", "items": gin.H{"$ref": "#/components/schemas/Error"}},
+			},
+		},
+	}}
+
+	registryMu.Lock()
+	resources := registry[r]
+	registryMu.Unlock()
+
+	for _, res := range resources {
+		res.document(spec)
+	}
+
+	version := config.Version
+	if version == "" {
+		version = "1.0.0"
+	}
+
+	info := gin.H{"title": openAPITitle(config), "version": version}
+	if config.Description != "" {
+		info["description"] = config.Description
+	}
+
+	return gin.H{
+		"openapi":    "3.1.0",
+		"info":       info,
+		"paths":      spec.paths,
+		"components": gin.H{"schemas": spec.schemas},
+	}
+}
+
+func openAPITitle(config *OpenAPIConfig) string {
+	if config.Title == "" {
+		return "REST API"
+	}
+
+	return config.Title
+}
+
+type openAPISpec struct {
+	paths   gin.H
+	schemas gin.H
+}
+
+var pathParamRegexp = regexp.MustCompile(`:(\w+)`)
+
+func (res *Resource[M]) document(spec *openAPISpec) {
+	for _, rt := range res.routes {
+		path := pathParamRegexp.ReplaceAllString(rt.path, "{$1}")
+		if spec.paths[path] == nil {
+			spec.paths[path] = gin.H{}
+		}
+
+		spec.paths[path].(gin.H)[strings.ToLower(rt.method)] = spec.operation(res.name, res.config, rt)
+	}
+}
+
+func (spec *openAPISpec) operation(resource string, config *ApiConfig, rt route) gin.H {
+	responses := gin.H{}
+	op := gin.H{
+		"tags":        []string{resource},
+		"operationId": fmt.Sprintf("%v%v", strings.ToLower(rt.method), strcase.ToCamel(strings.NewReplacer("/", " ", ":", "by ").Replace(rt.path))),
+		"responses":   responses,
+	}
+
+	params := []gin.H{}
+	for _, p := range pathParamRegexp.FindAllStringSubmatch(rt.path, -1) {
+		params = append(params, gin.H{"name": p[1], "in": "path", "required": true, "schema": gin.H{"type": "string"}})
+	}
+
+	errorResponse := gin.H{"description": "Error", "content": jsonContent(gin.H{"$ref": "#/components/schemas/Errors"})}
+	if len(params) > 0 {
+		responses["404"] = errorResponse
+	}
+	if config.Permission != nil {
+		responses["403"] = errorResponse
+	}
+
+	if rt.model == nil {
+		op["summary"] = fmt.Sprintf("%v action", strcase.ToCamel(string(rt.action)))
+		responses["200"] = gin.H{"description": "Successful response"}
+		op["parameters"] = params
+		return op
+	}
+
+	ref := spec.schemaOf(rt.model)
+	name := strings.TrimPrefix(fmt.Sprint(ref["$ref"]), "#/components/schemas/")
+
+	switch rt.action {
+	case ActionList:
+		op["summary"] = fmt.Sprintf("List %v", name)
+		params = append(params, spec.listParameters(rt.model)...)
+		if config != nil {
+			params = append(params, scopeParameters(config.Scopes)...)
+		}
+		if config != nil && config.Search != nil {
+			params = append(params, gin.H{"name": "search", "in": "query", "description": "Search the results by " + strings.Join(config.Search.Fields, ", "), "schema": gin.H{"type": "string"}})
+		}
+		responses["200"] = gin.H{"description": "List of " + name, "content": jsonContent(gin.H{
+			"type": "object",
+			"properties": gin.H{
+				"data":   gin.H{"type": "array", "items": ref},
+				"errors": gin.H{"type": "array", "items": gin.H{"$ref": "#/components/schemas/Error"}},
+			},
+		})}
+		responses["400"] = errorResponse
+	case ActionRetrieve:
+		op["summary"] = fmt.Sprintf("Get %v", name)
+		params = append(params, formatParameter())
+		responses["200"] = gin.H{"description": name, "content": jsonContent(gin.H{
+			"type":       "object",
+			"properties": gin.H{"data": ref},
+		})}
+	case ActionCreate:
+		op["summary"] = fmt.Sprintf("Create %v", name)
+		op["requestBody"] = gin.H{"required": true, "content": jsonContent(ref)}
+		responses["201"] = gin.H{"description": "Created " + name, "content": jsonContent(gin.H{
+			"type":       "object",
+			"properties": gin.H{"data": ref},
+		})}
+		responses["400"] = errorResponse
+	case ActionUpdate:
+		op["summary"] = fmt.Sprintf("Update %v", name)
+		op["requestBody"] = gin.H{"required": true, "content": jsonContent(ref)}
+		responses["204"] = gin.H{"description": "Updated"}
+		responses["400"] = errorResponse
+		if config != nil && (config.VersionField != "" || hasVersionTag(rt.model)) {
+			responses["409"] = errorResponse
+		}
+	case ActionDelete:
+		op["summary"] = fmt.Sprintf("Delete %v", name)
+		responses["204"] = gin.H{"description": "Deleted"}
+	}
+
+	if config != nil && config.ETag != nil {
+		switch rt.action {
+		case ActionList, ActionRetrieve:
+			responses["304"] = gin.H{"description": "Not modified"}
+			params = append(params, gin.H{"name": "If-None-Match", "in": "header", "schema": gin.H{"type": "string"}})
+		case ActionUpdate, ActionDelete:
+			responses["412"] = errorResponse
+			params = append(params, gin.H{"name": "If-Match", "in": "header", "required": config.ETag.RequireIfMatch, "schema": gin.H{"type": "string"}})
+			if config.ETag.RequireIfMatch {
+				responses["428"] = errorResponse
+			}
+		}
+	}
+
+	if config != nil && config.HTTPCache != nil && config.HTTPCache.LastModifiedField != "-" && rt.action == ActionRetrieve {
+		responses["304"] = gin.H{"description": "Not modified"}
+		params = append(params, gin.H{"name": "If-Modified-Since", "in": "header", "schema": gin.H{"type": "string"}})
+	}
+
+	op["parameters"] = params
+	return op
+}
+
+// formatParameter documents the formats of the registered renderers
+func formatParameter() gin.H {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+
+	formats := []string{}
+	for _, r := range renderers {
+		formats = append(formats, r.format)
+	}
+
+	return gin.H{"name": "format", "in": "query", "description": "Response format, negotiated with the Accept header when missing", "schema": gin.H{"type": "string", "enum": formats}}
+}
+
+func jsonContent(schema gin.H) gin.H {
+	return gin.H{"application/json": gin.H{"schema": schema}}
+}
+
+// filterOperators are the operators accepted by the list conditions, by JSON type
+var filterOperators = map[string][]string{
+	"integer": {"gt", "gte", "lt", "lte"},
+	"number":  {"gt", "gte", "lt", "lte"},
+	"string":  {"gt", "gte", "lt", "lte", "startswith", "endswith", "contains"},
+	"boolean": {},
+}
+
+func (spec *openAPISpec) listParameters(model reflect.Type) []gin.H {
+	params := []gin.H{
+		{"name": "fields", "in": "query", "description": "Comma separated fields to retrieve", "schema": gin.H{"type": "string"}},
+		{"name": "order", "in": "query", "description": "Comma separated fields to sort by, prefixed with - for descending order", "schema": gin.H{"type": "string"}},
+		{"name": "limit", "in": "query", "schema": gin.H{"type": "integer", "minimum": 0}},
+		{"name": "offset", "in": "query", "schema": gin.H{"type": "integer", "minimum": 0}},
+		formatParameter(),
+		{"name": "stream", "in": "query", "description": "Write the results while they are read from the database", "schema": gin.H{"type": "boolean"}},
+	}
+
+	for _, f := range modelFields(model) {
+		t := f.Type
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		schema := spec.schemaOf(t)
+		jsonType := schema["type"]
+		if types, ok := jsonType.([]string); ok {
+			jsonType = types[0]
+			schema = gin.H{"type": types[0], "format": schema["format"]}
+		}
+
+		operators, ok := filterOperators[fmt.Sprint(jsonType)]
+		if !ok {
+			continue
+		}
+
+		column := strcase.ToSnake(f.Name)
+		params = append(params, gin.H{"name": column, "in": "query", "schema": schema})
+		for _, op := range operators {
+			params = append(params, gin.H{"name": fmt.Sprintf("%v__%v", column, op), "in": "query", "schema": schema})
+		}
+	}
+
+	return params
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	nullTimeType  = reflect.TypeOf(sql.NullTime{})
+	deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+)
+
+// schemaOf returns the JSON schema of the type, named structs are added to the
+// components and referenced
+func (spec *openAPISpec) schemaOf(t reflect.Type) gin.H {
+	switch t {
+	case timeType:
+		return gin.H{"type": "string", "format": "date-time"}
+	case nullTimeType, deletedAtType:
+		return gin.H{"type": []string{"string", "null"}, "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := spec.schemaOf(t.Elem())
+		if s, ok := schema["type"].(string); ok {
+			schema["type"] = []string{s, "null"}
+			return schema
+		}
+		return gin.H{"anyOf": []gin.H{schema, {"type": "null"}}}
+	case reflect.Bool:
+		return gin.H{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return gin.H{"type": "integer", "format": integerFormat(t)}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return gin.H{"type": "integer", "format": integerFormat(t), "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return gin.H{"type": "number"}
+	case reflect.String:
+		return gin.H{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return gin.H{"type": "string", "format": "byte"}
+		}
+		return gin.H{"type": "array", "items": spec.schemaOf(t.Elem())}
+	case reflect.Map:
+		return gin.H{"type": "object", "additionalProperties": spec.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return spec.objectSchema(t)
+		}
+
+		ref := gin.H{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := spec.schemas[t.Name()]; !ok {
+			// Placeholder to stop the recursion on models referencing each other
+			spec.schemas[t.Name()] = gin.H{}
+			spec.schemas[t.Name()] = spec.objectSchema(t)
+		}
+		return ref
+	}
+
+	return gin.H{}
+}
+
+// integerFormat returns the OpenAPI format of the integer type, int32 or int64
+func integerFormat(t reflect.Type) string {
+	if t.Bits() > 32 {
+		return "int64"
+	}
+
+	return "int32"
+}
+
+func (spec *openAPISpec) objectSchema(t reflect.Type) gin.H {
+	properties := gin.H{}
+	required := []string{}
+
+	for _, f := range modelFields(t) {
+		name, ok := jsonName(f)
+		if !ok {
+			continue
+		}
+
+		schema := spec.schemaOf(f.Type)
+		if applyBindingRules(schema, f) {
+			required = append(required, name)
+		}
+		properties[name] = schema
+	}
+
+	schema := gin.H{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+// applyBindingRules documents the validations of the binding tag in the schema,
+// it returns true when the field is required
+func applyBindingRules(schema gin.H, f reflect.StructField) bool {
+	required := false
+	t := f.Type
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	bounds := map[string]string{"min": "minimum", "max": "maximum", "gte": "minimum", "lte": "maximum", "gt": "exclusiveMinimum", "lt": "exclusiveMaximum"}
+	switch t.Kind() {
+	case reflect.String:
+		bounds = map[string]string{"min": "minLength", "max": "maxLength", "len": "minLength"}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		bounds = map[string]string{"min": "minItems", "max": "maxItems", "len": "minItems"}
+	}
+
+	for _, rule := range strings.Split(f.Tag.Get("binding"), ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "email":
+			schema["format"] = "email"
+		case "url", "uri":
+			schema["format"] = "uri"
+		case "uuid":
+			schema["format"] = "uuid"
+		case "oneof":
+			enum := []interface{}{}
+			for _, v := range strings.Fields(arg) {
+				if n, err := strconv.ParseFloat(v, 64); err == nil && t.Kind() != reflect.String {
+					enum = append(enum, n)
+				} else {
+					enum = append(enum, v)
+				}
+			}
+			schema["enum"] = enum
+		default:
+			if keyword, ok := bounds[name]; ok {
+				if n, err := strconv.ParseFloat(arg, 64); err == nil {
+					schema[keyword] = n
+					if name == "len" {
+						schema[strings.Replace(keyword, "min", "max", 1)] = n
+					}
+				}
+			}
+		}
+	}
+
+	return required
+}
@@ -0,0 +1,291 @@
+package drilldown
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// OutboxConfig records an event of each create, update and delete of the generated routes
+// in the outbox table, in the same transaction as the write, to be published by a Dispatcher
+type OutboxConfig struct {
+	// Name of the entity in the topics of the events, ex: book for book.created,
+	// the singular name of the resource by default
+	Name string
+	// Table of the events, outbox_events by default, see OutboxEvent
+	Table string
+}
+
+// OutboxEvent is an event waiting in the outbox, migrate it with
+// DB.AutoMigrate(&drilldown.OutboxEvent{}), or DB.Table(table).AutoMigrate for another table
+type OutboxEvent struct {
+	ID     uint64 `json:"id"`
+	Topic  string `json:"topic" gorm:"size:191"`
+	Entity string `json:"entity" gorm:"size:191;index:idx_outbox_item"`
+	ItemID string `json:"item_id" gorm:"size:191;index:idx_outbox_item"`
+	// Payload is the item after the change, or the deleted one
+	Payload       json.RawMessage `json:"payload" gorm:"type:text"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Attempts      int             `json:"-"`
+	NextAttemptAt *time.Time      `json:"-"`
+	LastError     string          `json:"-" gorm:"type:text"`
+	PublishedAt   *time.Time      `json:"-" gorm:"index"`
+	// FailedAt is set when the event exceeded the MaxAttempts of the Dispatcher, it is no
+	// longer retried nor holds the later events of its item. Clear it to retry the event
+	FailedAt *time.Time `json:"-"`
+}
+
+func outboxTable(table string) string {
+	if table == "" {
+		return "outbox_events"
+	}
+
+	return table
+}
+
+var eventNames = map[Action]string{
+	ActionCreate: "created",
+	ActionUpdate: "updated",
+	ActionDelete: "deleted",
+}
+
+// enqueue records the event of the change of the item in the transaction of the write
+func (res *Resource[M]) enqueue(c *gin.Context, tx *gorm.DB, action Action, item *M) error {
+	config := res.config.Outbox
+	if config == nil {
+		return nil
+	}
+
+	name := config.Name
+	if name == "" {
+		name = removePlural(res.name)
+	}
+
+	key, err := res.primaryKey(c, item)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	event := OutboxEvent{
+		Topic:   fmt.Sprintf("%v.%v", name, eventNames[action]),
+		Entity:  name,
+		ItemID:  key,
+		Payload: payload,
+	}
+
+	return tx.Session(&gorm.Session{NewDB: true}).Table(outboxTable(config.Table)).Create(&event).Error
+}
+
+// Publisher delivers the events of the outbox, an error retries the event later
+type Publisher interface {
+	Publish(ctx context.Context, event *OutboxEvent) error
+}
+
+// PublisherFunc is a Publisher implemented by a function, ex: to send the events to a broker
+type PublisherFunc func(ctx context.Context, event *OutboxEvent) error
+
+func (f PublisherFunc) Publish(ctx context.Context, event *OutboxEvent) error {
+	return f(ctx, event)
+}
+
+// ChannelPublisher sends the events to the channel, for the consumers in the same process
+func ChannelPublisher(events chan<- OutboxEvent) Publisher {
+	return PublisherFunc(func(ctx context.Context, event *OutboxEvent) error {
+		select {
+		case events <- *event:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// WebhookPublisher posts the events as JSON to the URL, with their ID in the X-Event-ID header
+// so the receivers can discard the duplicates. The responses other than 2xx are retried
+func WebhookPublisher(url string, client *http.Client) Publisher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return PublisherFunc(func(ctx context.Context, event *OutboxEvent) error {
+		body, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Event-ID", fmt.Sprint(event.ID))
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("webhook %v responded %v", url, resp.Status)
+		}
+
+		return nil
+	})
+}
+
+// Dispatcher publishes the events of the outbox at least once, in order for each item:
+// the events after a failed one wait for it to be retried, the ones of the other items
+// are published meanwhile. Run a single dispatcher for each database
+type Dispatcher struct {
+	// DB with the outbox table, DB when nil
+	DB *gorm.DB
+	// Table of the events, outbox_events by default
+	Table     string
+	Publisher Publisher
+	// Interval between the polls of the outbox, a second by default
+	Interval time.Duration
+	// BatchSize is the maximum of events read by each poll, 100 by default
+	BatchSize int
+	// Backoff returns the delay before retrying an event failed the given times,
+	// doubling from a second up to 5 minutes by default
+	Backoff func(attempts int) time.Duration
+	// MaxAttempts to publish an event before setting it aside as failed, see OutboxEvent.FailedAt,
+	// retried indefinitely when zero
+	MaxAttempts int
+	// OnError is called with the errors of the polls and of the publishing of the events, ex: to log them
+	OnError func(err error)
+}
+
+func (d *Dispatcher) report(err error) {
+	if d.OnError != nil {
+		d.OnError(err)
+	}
+}
+
+func defaultBackoff(attempts int) time.Duration {
+	delay := time.Second
+	for i := 1; i < attempts && delay < 5*time.Minute; i++ {
+		delay *= 2
+	}
+
+	if delay > 5*time.Minute {
+		return 5 * time.Minute
+	}
+
+	return delay
+}
+
+// Run publishes the events until the context is done
+func (d *Dispatcher) Run(ctx context.Context) error {
+	interval := d.Interval
+	if interval == 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// the failed polls are retried on the next tick
+		if _, err := d.Dispatch(ctx); err != nil && ctx.Err() == nil {
+			d.report(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch publishes the pending events once, returning how many were published
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	db := d.DB
+	if db == nil {
+		db = DB
+	}
+	db = db.WithContext(ctx)
+
+	size := d.BatchSize
+	if size == 0 {
+		size = 100
+	}
+
+	backoff := d.Backoff
+	if backoff == nil {
+		backoff = defaultBackoff
+	}
+
+	// the events waiting to be retried, and the later ones of their items, are left out
+	// so they don't fill the batches of the other items
+	table := outboxTable(d.Table)
+	polled := time.Now()
+	var events []OutboxEvent
+	err := db.Table(table).
+		Where("published_at IS NULL AND failed_at IS NULL").
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", polled).
+		Where(fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %[1]v waiting WHERE waiting.entity = %[1]v.entity AND waiting.item_id = %[1]v.item_id "+
+			"AND waiting.id < %[1]v.id AND waiting.published_at IS NULL AND waiting.failed_at IS NULL AND waiting.next_attempt_at > ?)",
+			db.Statement.Quote(table)), polled).
+		Order("id").Limit(size).Find(&events).Error
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	blocked := map[string]bool{}
+	for i := range events {
+		event := &events[i]
+		entity := event.Entity + ":" + event.ItemID
+		if blocked[entity] {
+			continue
+		}
+
+		now := time.Now()
+		event.Attempts++
+		if err := d.Publisher.Publish(ctx, event); err != nil {
+			if ctx.Err() != nil {
+				return published, ctx.Err()
+			}
+
+			d.report(fmt.Errorf("publishing event %v of %v %v: %w", event.ID, event.Entity, event.ItemID, err))
+
+			updates := map[string]interface{}{"attempts": event.Attempts, "last_error": err.Error()}
+			if d.MaxAttempts > 0 && event.Attempts >= d.MaxAttempts {
+				updates["failed_at"] = now
+			} else {
+				blocked[entity] = true
+				updates["next_attempt_at"] = now.Add(backoff(event.Attempts))
+			}
+
+			if err := db.Table(table).Where("id = ?", event.ID).Updates(updates).Error; err != nil {
+				return published, err
+			}
+			continue
+		}
+
+		err = db.Table(table).Where("id = ?", event.ID).Updates(map[string]interface{}{
+			"attempts":     event.Attempts,
+			"published_at": now,
+		}).Error
+		if err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	return published, nil
+}
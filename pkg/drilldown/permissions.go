@@ -0,0 +1,89 @@
+package drilldown
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Action identifies an operation performed on a resource
+type Action string
+
+const (
+	ActionList     Action = "list"
+	ActionRetrieve Action = "retrieve"
+	ActionCreate   Action = "create"
+	ActionUpdate   Action = "update"
+	ActionDelete   Action = "delete"
+)
+
+// Permission controls the access to a resource
+type Permission interface {
+	// HasPermission tells if the request can perform the action at all,
+	// it is checked before touching the database
+	HasPermission(c *gin.Context, action Action) bool
+	// HasObjectPermission tells if the request can perform the action on an item
+	// that is visible to it, it is checked after the lookup and before creation
+	HasObjectPermission(c *gin.Context, action Action, item interface{}) bool
+	// Filter restricts the rows visible to the request, it is applied to the list,
+	// to the item lookup and to the update and delete statements.
+	// Rows filtered out are reported as not found instead of forbidden
+	Filter(c *gin.Context, db *gorm.DB) *gorm.DB
+}
+
+// AllowAll is a Permission that grants everything,
+// embed it to implement only the checks you need
+type AllowAll struct{}
+
+func (AllowAll) HasPermission(c *gin.Context, action Action) bool {
+	return true
+}
+
+func (AllowAll) HasObjectPermission(c *gin.Context, action Action, item interface{}) bool {
+	return true
+}
+
+func (AllowAll) Filter(c *gin.Context, db *gorm.DB) *gorm.DB {
+	return db
+}
+
+var errPermissionDenied = NewHTTPError(http.StatusForbidden, "You do not have permission to perform this action")
+
+func methodAction(method string) Action {
+	switch method {
+	case http.MethodPut, http.MethodPatch:
+		return ActionUpdate
+	case http.MethodDelete:
+		return ActionDelete
+	case http.MethodPost:
+		return ActionCreate
+	}
+
+	return ActionRetrieve
+}
+
+func checkPermission(c *gin.Context, config *ApiConfig, action Action) error {
+	if config == nil || config.Permission == nil || config.Permission.HasPermission(c, action) {
+		return nil
+	}
+
+	return errPermissionDenied
+}
+
+func checkObjectPermission(c *gin.Context, config *ApiConfig, action Action, item interface{}) error {
+	if config == nil || config.Permission == nil || config.Permission.HasObjectPermission(c, action, item) {
+		return nil
+	}
+
+	return errPermissionDenied
+}
+
+func filterRows(c *gin.Context, config *ApiConfig, db *gorm.DB) *gorm.DB {
+	db = filterTenant(c, config, db)
+	if config == nil || config.Permission == nil {
+		return db
+	}
+
+	return config.Permission.Filter(c, db)
+}
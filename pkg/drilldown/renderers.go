@@ -0,0 +1,307 @@
+package drilldown
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+)
+
+// Response is the body of the list and single item routes, given to the renderers
+type Response struct {
+	// Data is the list of results or the item
+	Data   interface{}
+	Errors []*HTTPError
+	List   bool
+
+	columns      func(rows []map[string]interface{}) []string
+	rows         []map[string]interface{}
+	etag         string
+	lastModified time.Time
+}
+
+// Body returns the response as it is sent in JSON
+func (r *Response) Body() gin.H {
+	if r.List {
+		errs := r.Errors
+		if errs == nil {
+			errs = []*HTTPError{}
+		}
+		return gin.H{"data": r.Data, "errors": errs}
+	}
+
+	return gin.H{"data": r.Data}
+}
+
+// Rows returns the results, or the item, as a list of maps keyed by their JSON names
+func (r *Response) Rows() []map[string]interface{} {
+	if r.rows != nil {
+		return r.rows
+	}
+
+	r.rows = []map[string]interface{}{}
+	encoded, err := json.Marshal(r.Data)
+	if err != nil {
+		return r.rows
+	}
+
+	if r.List {
+		decodeJSON(encoded, &r.rows)
+	} else {
+		var item map[string]interface{}
+		if decodeJSON(encoded, &item) == nil && item != nil {
+			r.rows = append(r.rows, item)
+		}
+	}
+
+	return r.rows
+}
+
+// Columns returns the keys of the rows, in the order of the fields parameter when
+// given or in the order of the fields of the model
+func (r *Response) Columns() []string {
+	if r.columns != nil {
+		return r.columns(r.Rows())
+	}
+
+	columns := []string{}
+	for _, row := range r.Rows() {
+		for k := range row {
+			columns = append(columns, k)
+		}
+		break
+	}
+	sort.Strings(columns)
+
+	return columns
+}
+
+// Renderer writes the response in a given format
+type Renderer interface {
+	Render(c *gin.Context, status int, response *Response) error
+}
+
+// RendererFunc adapts a function to a Renderer
+type RendererFunc func(c *gin.Context, status int, response *Response) error
+
+func (f RendererFunc) Render(c *gin.Context, status int, response *Response) error {
+	return f(c, status, response)
+}
+
+type registeredRenderer struct {
+	format      string
+	contentType string
+	renderer    Renderer
+}
+
+// formatHTML is the browsable API, rendered by the resource itself
+const formatHTML = "html"
+
+var (
+	renderersMu sync.RWMutex
+	renderers   = []registeredRenderer{
+		{"json", gin.MIMEJSON, jsonRenderer{}},
+		{formatHTML, gin.MIMEHTML, nil},
+		{"csv", "text/csv", csvRenderer{}},
+		{"ndjson", "application/x-ndjson", ndjsonRenderer{}},
+		{"xml", gin.MIMEXML, RendererFunc(renderXML)},
+		{"msgpack", "application/msgpack", RendererFunc(renderMsgPack)},
+	}
+)
+
+// RegisterRenderer adds a renderer to the list and single item routes, selected
+// with ?format=<format> or by the Accept header matching the content type.
+// It replaces the renderer of an existing format
+func RegisterRenderer(format string, contentType string, renderer Renderer) {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+
+	for i, r := range renderers {
+		if r.format == format {
+			renderers[i] = registeredRenderer{format, contentType, renderer}
+			return
+		}
+	}
+
+	renderers = append(renderers, registeredRenderer{format, contentType, renderer})
+}
+
+// negotiateRenderer picks the renderer from the format parameter or the Accept header,
+// JSON when nothing matches. It returns false when the format parameter is unknown
+func negotiateRenderer(c *gin.Context) (registeredRenderer, bool) {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+
+	if format := c.Query("format"); format != "" {
+		for _, r := range renderers {
+			if r.format == format {
+				return r, true
+			}
+		}
+
+		return registeredRenderer{}, false
+	}
+
+	offered := []string{}
+	for _, r := range renderers {
+		offered = append(offered, r.contentType)
+	}
+
+	contentType := c.NegotiateFormat(offered...)
+	for _, r := range renderers {
+		if r.contentType == contentType {
+			return r, true
+		}
+	}
+
+	return renderers[0], true
+}
+
+// respond renders the response in the format negotiated with the client
+func (res *Resource[M]) respond(c *gin.Context, status int, response *Response) {
+	response.columns = func(rows []map[string]interface{}) []string {
+		return res.columns(rows, c.Query("fields"))
+	}
+
+	r, ok := negotiateRenderer(c)
+	if !ok {
+		abortWithError(c, res.config, NewFieldError(http.StatusNotAcceptable, "unknown_format", "format", fmt.Sprintf("Unknown format: %v", c.Query("format"))))
+		return
+	}
+
+	if status == http.StatusOK {
+		if err := res.cacheHeaders(c, response); err != nil {
+			abortWithError(c, res.config, err)
+			return
+		}
+
+		if notModified(c, c.Writer.Header()) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	if r.format == formatHTML {
+		res.renderBrowsable(c, status, response)
+		return
+	}
+
+	if err := r.renderer.Render(c, status, response); err != nil {
+		abortWithError(c, res.config, err)
+	}
+}
+
+type jsonRenderer struct{}
+
+func (jsonRenderer) Render(c *gin.Context, status int, response *Response) error {
+	c.JSON(status, response.Body())
+	return nil
+}
+
+func renderMsgPack(c *gin.Context, status int, response *Response) error {
+	c.Render(status, render.MsgPack{Data: response.Body()})
+	return nil
+}
+
+type csvRenderer struct{}
+
+func (r csvRenderer) Render(c *gin.Context, status int, response *Response) error {
+	return renderRows(r, c, status, response)
+}
+
+type ndjsonRenderer struct{}
+
+func (r ndjsonRenderer) Render(c *gin.Context, status int, response *Response) error {
+	return renderRows(r, c, status, response)
+}
+
+// renderRows renders the whole response with the row writer of a stream renderer
+func renderRows(r StreamRenderer, c *gin.Context, status int, response *Response) error {
+	w, err := r.Stream(c, status, response.Columns())
+	if err != nil {
+		return err
+	}
+
+	for _, row := range response.Rows() {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return w.Close()
+}
+
+func renderXML(c *gin.Context, status int, response *Response) error {
+	c.Status(status)
+	c.Header("Content-Type", "application/xml; charset=utf-8")
+
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString("<response>")
+
+	columns := response.Columns()
+	writeItem := func(tag string, row map[string]interface{}) {
+		b.WriteString("<" + tag + ">")
+		for _, col := range columns {
+			value := valueAt(row, col)
+			if value == nil {
+				continue
+			}
+			b.WriteString("<" + col + ">")
+			xml.EscapeText(&b, []byte(formatValue(value)))
+			b.WriteString("</" + col + ">")
+		}
+		b.WriteString("</" + tag + ">")
+	}
+
+	if response.List {
+		b.WriteString("<data>")
+		for _, row := range response.Rows() {
+			writeItem("item", row)
+		}
+		b.WriteString("</data><errors>")
+		for _, e := range response.Errors {
+			b.WriteString("<error>")
+			xml.EscapeText(&b, []byte(e.Message))
+			b.WriteString("</error>")
+		}
+		b.WriteString("</errors>")
+	} else if rows := response.Rows(); len(rows) > 0 {
+		writeItem("data", rows[0])
+	}
+
+	b.WriteString("</response>")
+	_, err := c.Writer.WriteString(b.String())
+	return err
+}
+
+// decodeJSON decodes the data keeping the numbers as json.Number,
+// so the integers aren't rounded or formatted in exponent notation
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// formatValue formats a JSON value as text, nested values are kept in JSON
+func formatValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case map[string]interface{}, []interface{}:
+		encoded, _ := json.Marshal(value)
+		return string(encoded)
+	}
+
+	return fmt.Sprint(v)
+}
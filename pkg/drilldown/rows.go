@@ -0,0 +1,249 @@
+package drilldown
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
+// schema returns the parsed schema of the model, with the naming strategy of DB when set
+func (res *Resource[M]) schema() (*schema.Schema, error) {
+	res.schemaOnce.Do(func() {
+		res.parsedSchema, res.schemaErr = parseModel(&res.model)
+	})
+
+	return res.parsedSchema, res.schemaErr
+}
+
+// scanRows reads the rows of the list query into items of the model.
+// The values that don't belong to the model, the fields of the referenced tables,
+// are returned apart for each item, keyed by their column alias
+func (res *Resource[M]) scanRows(rows *sql.Rows) ([]M, []map[string]interface{}, error) {
+	items := []M{}
+	related := []map[string]interface{}{}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for rows.Next() {
+		item, values, err := res.scanRow(rows, columns)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		items = append(items, item)
+		related = append(related, values)
+	}
+
+	return items, related, rows.Err()
+}
+
+// scanRow reads the current row, the values of the model scanned and set by its
+// fields like gorm does, so NULLs and serializers are handled the same way
+func (res *Resource[M]) scanRow(rows *sql.Rows, columns []string) (M, map[string]interface{}, error) {
+	var item M
+	sch, err := res.schema()
+	if err != nil {
+		return item, nil, err
+	}
+
+	dests := make([]interface{}, len(columns))
+	fields := make([]*schema.Field, len(columns))
+	relatedValues := map[string]*interface{}{}
+	scanned := map[*schema.Field]bool{}
+	for i, col := range columns {
+		// On joins the first column with the name is the one of the model table
+		if field, ok := sch.FieldsByDBName[col]; ok && !scanned[field] {
+			scanned[field] = true
+			fields[i] = field
+			dests[i] = field.NewValuePool.Get()
+		} else {
+			var value interface{}
+			if strings.Contains(col, ".") {
+				relatedValues[col] = &value
+			}
+			dests[i] = &value
+		}
+	}
+
+	err = rows.Scan(dests...)
+	v := reflect.ValueOf(&item).Elem()
+	for i, field := range fields {
+		if field == nil {
+			continue
+		}
+		if err == nil {
+			err = field.Set(context.Background(), v, dests[i])
+		}
+		field.NewValuePool.Put(dests[i])
+	}
+	if err != nil {
+		return item, nil, err
+	}
+
+	related := map[string]interface{}{}
+	for col, value := range relatedValues {
+		if b, ok := (*value).([]byte); ok {
+			related[col] = string(b)
+		} else {
+			related[col] = *value
+		}
+	}
+
+	return item, related, nil
+}
+
+// project returns the items as they are sent to the client. Without fields they are
+// the items themselves, serialized as in the single item routes, otherwise only the
+// primary key and the fields selected are kept, with the fields of the referenced
+// tables nested, ex: authors.name as {"author": {"name": ...}}
+func (res *Resource[M]) project(items []M, related []map[string]interface{}, fields string) interface{} {
+	if fields == "" {
+		return items
+	}
+
+	keys := res.projectedKeys(fields)
+	projected := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		var values map[string]interface{}
+		if i < len(related) {
+			values = related[i]
+		}
+		projected[i] = projectItem(item, values, keys)
+	}
+
+	return projected
+}
+
+// projectedKeys returns the JSON names of the primary key and of the fields selected
+func (res *Resource[M]) projectedKeys(fields string) []string {
+	keys := []string{}
+	sch, err := res.schema()
+	if err != nil {
+		return keys
+	}
+
+	for _, f := range sch.PrimaryFields {
+		if name, ok := jsonName(f.StructField); ok {
+			keys = append(keys, name)
+		}
+	}
+	for _, f := range strings.Split(fields, ",") {
+		if field, ok := sch.FieldsByDBName[f]; ok {
+			if name, ok := jsonName(field.StructField); ok {
+				keys = append(keys, name)
+			}
+		}
+	}
+
+	return keys
+}
+
+func projectItem(item interface{}, related map[string]interface{}, keys []string) map[string]interface{} {
+	full := toMap(item)
+	projected := map[string]interface{}{}
+	for _, k := range keys {
+		if v, ok := full[k]; ok {
+			projected[k] = v
+		}
+	}
+
+	for col, value := range related {
+		setNested(projected, col, value)
+	}
+
+	return projected
+}
+
+// columns returns the keys of the rows, in the order of the fields parameter when
+// given or ordered as the scalar fields of the model, referenced tables fields are
+// returned as paths, ex: author.name
+func (res *Resource[M]) columns(rows []map[string]interface{}, fields string) []string {
+	if fields != "" {
+		sch, _ := res.schema()
+		columns := []string{}
+		for _, f := range strings.Split(fields, ",") {
+			if table, field, ok := strings.Cut(f, "."); ok {
+				// Referenced table fields are aliased with the singular table name
+				f = fmt.Sprintf("%v.%v", removePlural(table), field)
+			} else if sch != nil && sch.FieldsByDBName[f] != nil {
+				f, _ = jsonName(sch.FieldsByDBName[f].StructField)
+			}
+			columns = append(columns, f)
+		}
+
+		return columns
+	}
+
+	keys := map[string]bool{}
+	for _, r := range rows {
+		for k := range r {
+			keys[k] = true
+		}
+	}
+
+	columns := []string{}
+	for _, f := range modelFields(reflect.TypeOf(res.model)) {
+		name, ok := jsonName(f)
+		if _, scalar := browsableInput(f.Type); ok && scalar {
+			columns = append(columns, name)
+		}
+		delete(keys, name)
+	}
+
+	others := []string{}
+	for k := range keys {
+		others = append(others, k)
+	}
+	sort.Strings(others)
+
+	return append(columns, others...)
+}
+
+// toMap converts the item to a map keyed by its JSON names
+func toMap(item interface{}) map[string]interface{} {
+	m := map[string]interface{}{}
+	if encoded, err := json.Marshal(item); err == nil {
+		decodeJSON(encoded, &m)
+	}
+
+	return m
+}
+
+// setNested sets the value in the path of nested maps, ex: author.name
+func setNested(m map[string]interface{}, path string, value interface{}) {
+	key, rest, nested := strings.Cut(path, ".")
+	if !nested {
+		m[key] = value
+		return
+	}
+
+	child, ok := m[key].(map[string]interface{})
+	if !ok {
+		child = map[string]interface{}{}
+		m[key] = child
+	}
+	setNested(child, rest, value)
+}
+
+// valueAt returns the value in the path of nested maps, ex: author.name
+func valueAt(m map[string]interface{}, path string) interface{} {
+	if v, ok := m[path]; ok {
+		return v
+	}
+
+	key, rest, nested := strings.Cut(path, ".")
+	if child, ok := m[key].(map[string]interface{}); ok && nested {
+		return valueAt(child, rest)
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package drilldown
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Scope is a named filter of the list that the clients can apply
+type Scope struct {
+	Description string
+	// Param is the type of the argument of the scope: boolean, integer, number or string.
+	// Scopes with an argument are applied with their own query parameter, ex: ?published=true,
+	// the rest are selected with the scope parameter, ex: ?scope=scifi,long
+	Param string
+	// Apply receives the argument converted to the type of Param, nil without it
+	Apply func(db *gorm.DB, arg interface{}) *gorm.DB
+}
+
+// applyScopes applies the scopes selected by the query, returning the query
+// without their parameters, to be used as filters
+func applyScopes(db *gorm.DB, scopes map[string]Scope, query url.Values) (*gorm.DB, url.Values, Errors) {
+	var errs Errors
+	filters := url.Values{}
+	for k, v := range query {
+		filters[k] = v
+	}
+
+	if selected := query.Get("scope"); selected != "" {
+		for _, name := range strings.Split(selected, ",") {
+			scope, ok := scopes[name]
+			if !ok || scope.Param != "" {
+				errs = append(errs, NewFieldError(http.StatusBadRequest, "invalid_parameter", "scope", fmt.Sprintf("Unknown scope: %v", name)))
+				continue
+			}
+			db = scope.Apply(db, nil)
+		}
+	}
+
+	for _, name := range scopeNames(scopes, true) {
+		value, ok := query[name]
+		if !ok {
+			continue
+		}
+		delete(filters, name)
+
+		param := scopes[name].Param
+		arg, err := parseScopeArg(param, value[0])
+		if err != nil {
+			article := "a"
+			if strings.ContainsAny(param[:1], "aeiou") {
+				article = "an"
+			}
+			errs = append(errs, NewFieldError(http.StatusBadRequest, "invalid_parameter", name, fmt.Sprintf("%v expects %v %v, received: %v", name, article, param, value[0])))
+			continue
+		}
+		db = scopes[name].Apply(db, arg)
+	}
+
+	return db, filters, errs
+}
+
+func parseScopeArg(param string, value string) (interface{}, error) {
+	switch param {
+	case "boolean":
+		return strconv.ParseBool(value)
+	case "integer":
+		return strconv.ParseInt(value, 10, 64)
+	case "number":
+		return strconv.ParseFloat(value, 64)
+	}
+
+	return value, nil
+}
+
+// scopeNames returns the sorted names of the scopes with or without argument
+func scopeNames(scopes map[string]Scope, withParam bool) []string {
+	names := []string{}
+	for name, scope := range scopes {
+		if (scope.Param != "") == withParam {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// scopeParameters documents the parameters of the scopes in the OpenAPI document
+func scopeParameters(scopes map[string]Scope) []gin.H {
+	params := []gin.H{}
+	if names := scopeNames(scopes, false); len(names) > 0 {
+		descriptions := []string{}
+		for _, name := range names {
+			if d := scopes[name].Description; d != "" {
+				descriptions = append(descriptions, fmt.Sprintf("%v: %v", name, d))
+			}
+		}
+
+		params = append(params, gin.H{
+			"name":        "scope",
+			"in":          "query",
+			"description": strings.Join(append([]string{"Comma separated scopes to apply"}, descriptions...), "\n"),
+			"style":       "form",
+			"explode":     false,
+			"schema":      gin.H{"type": "array", "items": gin.H{"type": "string", "enum": names}},
+		})
+	}
+
+	for _, name := range scopeNames(scopes, true) {
+		params = append(params, gin.H{
+			"name":        name,
+			"in":          "query",
+			"description": scopes[name].Description,
+			"schema":      gin.H{"type": scopes[name].Param},
+		})
+	}
+
+	return params
+}
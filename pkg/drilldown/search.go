@@ -0,0 +1,141 @@
+package drilldown
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SearchConfig sets the fields matched by the search parameter of the list
+type SearchConfig struct {
+	// Fields searched, ex: title, or authors.name for the fields of the referenced tables
+	Fields []string
+	// FullText uses the full text search of the database on the fields of the model:
+	// MATCH AGAINST on MySQL, which needs a FULLTEXT index on them, to_tsvector on Postgres
+	// and the FTS5 table given in Table on SQLite, with the ids of the model as rowid.
+	// The fields of the referenced tables, and the other databases, use LIKE
+	FullText bool
+	Table    string
+	// Rank orders the results by relevance when the order parameter is missing
+	Rank bool
+}
+
+// likeEscaper escapes the wildcards of the LIKE patterns, with ! as a backslash
+// would need to be escaped again in the string literals of MySQL
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// searchScope returns the scope filtering the list of the resource by the search term,
+// with the relevance order when ranked
+func searchScope(resource string, config *SearchConfig, term string, rank bool) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		local, related := []string{}, []string{}
+		joined := map[string]bool{}
+		for _, f := range config.Fields {
+			if !strings.Contains(f, ".") {
+				local = append(local, fmt.Sprintf("%v.%v", resource, f))
+				continue
+			}
+
+			// The referenced tables are joined once, with an alias to not clash with the fields selector
+			tableAndField := strings.SplitN(f, ".", 2)
+			alias := "search_" + tableAndField[0]
+			related = append(related, fmt.Sprintf("%v.%v", alias, tableAndField[1]))
+			if joined[alias] {
+				continue
+			}
+			joined[alias] = true
+			db = db.Joins(fmt.Sprintf("left join %v %v on %v = %v",
+				db.Statement.Quote(tableAndField[0]),
+				db.Statement.Quote(alias),
+				db.Statement.Quote(alias+".id"),
+				db.Statement.Quote(fmt.Sprintf("%v.%v_id", resource, removePlural(tableAndField[0]))),
+			))
+		}
+
+		likes := related
+		var match *clause.Expr
+		if config.FullText && len(local) > 0 {
+			match = fullTextMatch(db, resource, config, local, term)
+		}
+		if match == nil {
+			likes = append(local, related...)
+		}
+
+		conditions := []string{}
+		vars := []interface{}{}
+		if match != nil {
+			conditions = append(conditions, match.SQL)
+			vars = append(vars, match.Vars...)
+		}
+		for _, f := range likes {
+			conditions = append(conditions, fmt.Sprintf("%v LIKE ? ESCAPE '!'", db.Statement.Quote(f)))
+			vars = append(vars, "%"+likeEscaper.Replace(term)+"%")
+		}
+		db = db.Where(fmt.Sprintf("(%v)", strings.Join(conditions, " OR ")), vars...)
+
+		if rank && match != nil {
+			if relevance := fullTextRank(db, resource, config, local, term); relevance != nil {
+				db = db.Clauses(clause.OrderBy{Expression: *relevance})
+			}
+		}
+
+		return db
+	}
+}
+
+// fullTextMatch returns the full text condition for the dialect of the database, nil when unsupported
+func fullTextMatch(db *gorm.DB, resource string, config *SearchConfig, fields []string, term string) *clause.Expr {
+	switch db.Dialector.Name() {
+	case "mysql":
+		return &clause.Expr{SQL: fmt.Sprintf("MATCH (%v) AGAINST (? IN NATURAL LANGUAGE MODE)", quoteAll(db, fields)), Vars: []interface{}{term}}
+	case "postgres":
+		return &clause.Expr{SQL: fmt.Sprintf("%v @@ plainto_tsquery(?)", tsvector(db, fields)), Vars: []interface{}{term}}
+	case "sqlite":
+		if config.Table == "" {
+			return nil
+		}
+		return &clause.Expr{SQL: fmt.Sprintf("%v IN (SELECT rowid FROM %v WHERE %[2]v MATCH ?)",
+			db.Statement.Quote(resource+".id"), db.Statement.Quote(config.Table)), Vars: []interface{}{term}}
+	}
+
+	return nil
+}
+
+// fullTextRank returns the order by relevance for the dialect of the database, nil when unsupported
+func fullTextRank(db *gorm.DB, resource string, config *SearchConfig, fields []string, term string) *clause.Expr {
+	var expr clause.Expr
+	switch db.Dialector.Name() {
+	case "mysql":
+		expr = clause.Expr{SQL: fmt.Sprintf("MATCH (%v) AGAINST (? IN NATURAL LANGUAGE MODE) DESC", quoteAll(db, fields)), Vars: []interface{}{term}}
+	case "postgres":
+		expr = clause.Expr{SQL: fmt.Sprintf("ts_rank(%v, plainto_tsquery(?)) DESC", tsvector(db, fields)), Vars: []interface{}{term}}
+	case "sqlite":
+		// The rank of FTS5 is lower for the better matches
+		expr = clause.Expr{SQL: fmt.Sprintf("(SELECT rank FROM %v WHERE %[1]v MATCH ? AND rowid = %v)",
+			db.Statement.Quote(config.Table), db.Statement.Quote(resource+".id")), Vars: []interface{}{term}}
+	default:
+		return nil
+	}
+
+	return &expr
+}
+
+func quoteAll(db *gorm.DB, fields []string) string {
+	quoted := []string{}
+	for _, f := range fields {
+		quoted = append(quoted, db.Statement.Quote(f))
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+func tsvector(db *gorm.DB, fields []string) string {
+	coalesced := []string{}
+	for _, f := range fields {
+		coalesced = append(coalesced, fmt.Sprintf("coalesce(%v, '')", db.Statement.Quote(f)))
+	}
+
+	return fmt.Sprintf("to_tsvector(%v)", strings.Join(coalesced, " || ' ' || "))
+}
@@ -0,0 +1,198 @@
+package drilldown
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// streamFlushRows is the number of rows written between flushes of a streamed response
+const streamFlushRows = 100
+
+// RowWriter writes the rows of a streamed list
+type RowWriter interface {
+	Write(row map[string]interface{}) error
+	// Close finishes the response
+	Close() error
+}
+
+// StreamRenderer is a Renderer able to write the list results incrementally,
+// used when the list is requested with stream=true
+type StreamRenderer interface {
+	Renderer
+	// Stream writes the beginning of the response and returns the writer of the rows
+	Stream(c *gin.Context, status int, columns []string) (RowWriter, error)
+}
+
+// stream writes the results of the query while they are read from the database,
+// without loading them all in memory, calling the AfterList hook with each of them.
+// It stops when the client disconnects.
+// It returns false when the negotiated format can't be streamed
+func (res *Resource[M]) stream(c *gin.Context, q *gorm.DB) bool {
+	r, ok := negotiateRenderer(c)
+	if !ok {
+		return false
+	}
+
+	renderer, ok := r.renderer.(StreamRenderer)
+	if !ok {
+		return false
+	}
+
+	ctx := c.Request.Context()
+	rows, err := q.WithContext(ctx).Rows()
+	if err != nil {
+		abortWithError(c, res.config, err)
+		return true
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		abortWithError(c, res.config, err)
+		return true
+	}
+
+	fields := c.Query("fields")
+	keys := res.projectedKeys(fields)
+	w, err := renderer.Stream(c, http.StatusOK, res.columns(nil, fields))
+	if err != nil {
+		abortWithError(c, res.config, err)
+		return true
+	}
+
+	for count := 1; ctx.Err() == nil && rows.Next(); count++ {
+		item, related, err := res.scanRow(rows, columns)
+		if err != nil {
+			c.Error(err)
+			return true
+		}
+
+		// the AfterList hook sees the rows one at a time, and can remove them from the slice
+		items := []M{item}
+		if err := runHook(res.config.AfterList, c, q, &items); err != nil {
+			c.Error(err)
+			return true
+		}
+		if len(items) == 0 {
+			continue
+		}
+
+		var row map[string]interface{}
+		if fields == "" {
+			row = toMap(items[0])
+		} else {
+			row = projectItem(items[0], related, keys)
+		}
+
+		if err := w.Write(row); err != nil {
+			c.Error(err)
+			return true
+		}
+
+		if count%streamFlushRows == 0 {
+			c.Writer.Flush()
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		c.Error(err)
+	}
+
+	if err := w.Close(); err != nil {
+		c.Error(err)
+	}
+	c.Writer.Flush()
+
+	return true
+}
+
+type jsonRowWriter struct {
+	c     *gin.Context
+	count int
+}
+
+func (jsonRenderer) Stream(c *gin.Context, status int, columns []string) (RowWriter, error) {
+	c.Status(status)
+	c.Header("Content-Type", "application/json; charset=utf-8")
+
+	_, err := c.Writer.WriteString(`{"data":[`)
+	return &jsonRowWriter{c: c}, err
+}
+
+func (w *jsonRowWriter) Write(row map[string]interface{}) error {
+	if w.count > 0 {
+		if _, err := w.c.Writer.WriteString(","); err != nil {
+			return err
+		}
+	}
+	w.count++
+
+	encoded, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.c.Writer.Write(encoded)
+	return err
+}
+
+func (w *jsonRowWriter) Close() error {
+	_, err := w.c.Writer.WriteString(`],"errors":[]}`)
+	return err
+}
+
+type ndjsonRowWriter struct {
+	encoder *json.Encoder
+}
+
+func (ndjsonRenderer) Stream(c *gin.Context, status int, columns []string) (RowWriter, error) {
+	c.Status(status)
+	c.Header("Content-Type", "application/x-ndjson")
+
+	return &ndjsonRowWriter{encoder: json.NewEncoder(c.Writer)}, nil
+}
+
+func (w *ndjsonRowWriter) Write(row map[string]interface{}) error {
+	return w.encoder.Encode(row)
+}
+
+func (w *ndjsonRowWriter) Close() error {
+	return nil
+}
+
+type csvRowWriter struct {
+	writer  *csv.Writer
+	columns []string
+}
+
+func (csvRenderer) Stream(c *gin.Context, status int, columns []string) (RowWriter, error) {
+	c.Status(status)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+
+	w := &csvRowWriter{writer: csv.NewWriter(c.Writer), columns: columns}
+	return w, w.writer.Write(columns)
+}
+
+func (w *csvRowWriter) Write(row map[string]interface{}) error {
+	record := make([]string, len(w.columns))
+	for i, col := range w.columns {
+		record[i] = formatValue(valueAt(row, col))
+	}
+
+	if err := w.writer.Write(record); err != nil {
+		return err
+	}
+
+	// Rows are flushed to the response as they come
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvRowWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
@@ -0,0 +1,215 @@
+package drilldown
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Tenancy scopes a resource by the tenant of each request: the reads, updates and deletes
+// only reach the rows of the tenant, the creates set it and the updates can't change it
+type Tenancy struct {
+	// Resolve returns the tenant of the request, see TenantFromHeader, TenantFromSubdomain
+	// and TenantFromContext. Returning an error aborts the request
+	Resolve func(c *gin.Context) (interface{}, error)
+	// Column holding the tenant, tenant_id by default, "-" when the model doesn't have it
+	Column string
+	// DB returns the database of the tenant, optional, ex: a connection to its own database
+	// or schema. The rows are still filtered by the Column unless it is "-"
+	DB func(c *gin.Context, tenant interface{}) (*gorm.DB, error)
+}
+
+var errTenantRequired = &HTTPError{Status: http.StatusBadRequest, Code: "tenant_required", Message: "Tenant required"}
+
+// TenantFromHeader resolves the tenant from the value of the header
+func TenantFromHeader(name string) func(c *gin.Context) (interface{}, error) {
+	return func(c *gin.Context) (interface{}, error) {
+		tenant := c.GetHeader(name)
+		if tenant == "" {
+			return nil, errTenantRequired
+		}
+
+		return tenant, nil
+	}
+}
+
+// TenantFromSubdomain resolves the tenant from the first label of the host, ex: acme.example.com
+func TenantFromSubdomain() func(c *gin.Context) (interface{}, error) {
+	return func(c *gin.Context) (interface{}, error) {
+		host := c.Request.Host
+		if i := strings.LastIndex(host, ":"); i > strings.LastIndex(host, "]") {
+			host = host[:i]
+		}
+
+		labels := strings.Split(host, ".")
+		if len(labels) < 3 || labels[0] == "" {
+			return nil, errTenantRequired
+		}
+
+		return labels[0], nil
+	}
+}
+
+// TenantFromContext resolves the tenant from a value set in the context by a previous middleware,
+// ex: a claim of the token set by the authentication
+func TenantFromContext(key string) func(c *gin.Context) (interface{}, error) {
+	return func(c *gin.Context) (interface{}, error) {
+		tenant, ok := c.Get(key)
+		if !ok || tenant == nil {
+			return nil, errTenantRequired
+		}
+
+		return tenant, nil
+	}
+}
+
+func (t *Tenancy) column() string {
+	switch t.Column {
+	case "":
+		return "tenant_id"
+	case "-":
+		return ""
+	}
+
+	return t.Column
+}
+
+type tenantContext struct {
+	tenant interface{}
+	db     *gorm.DB
+}
+
+// tenantOf resolves the tenant of the request once, keeping it in the context, nil without tenancy
+func tenantOf(c *gin.Context, config *ApiConfig) (*tenantContext, error) {
+	if config == nil || config.Tenancy == nil {
+		return nil, nil
+	}
+
+	key := fmt.Sprintf("drilldown.tenant.%p", config.Tenancy)
+	if t, ok := c.Get(key); ok {
+		return t.(*tenantContext), nil
+	}
+
+	tenant, err := config.Tenancy.Resolve(c)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &tenantContext{tenant: tenant, db: DB}
+	if config.Tenancy.DB != nil {
+		if t.db, err = config.Tenancy.DB(c, tenant); err != nil {
+			return nil, err
+		}
+		if t.db == nil {
+			return nil, NewHTTPError(http.StatusNotFound, "Unknown tenant")
+		}
+	}
+
+	c.Set(key, t)
+	return t, nil
+}
+
+// resolveTenant is the middleware of the routes of the resources with tenancy,
+// it aborts the requests without a valid tenant
+func resolveTenant(config *ApiConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := tenantOf(c, config); err != nil {
+			abortWithError(c, config, err)
+		}
+	}
+}
+
+// withTenancy prepends the tenant resolution to the handler when the resource has tenancy
+func withTenancy(config *ApiConfig, handler gin.HandlerFunc) []gin.HandlerFunc {
+	if config == nil || config.Tenancy == nil {
+		return []gin.HandlerFunc{handler}
+	}
+
+	return []gin.HandlerFunc{resolveTenant(config), handler}
+}
+
+// database returns the database of the request, the one of its tenant when set
+func database(c *gin.Context, config *ApiConfig) *gorm.DB {
+	t, err := tenantOf(c, config)
+	if err != nil {
+		db := DB.Session(&gorm.Session{})
+		db.AddError(err)
+		return db
+	}
+
+	if t != nil {
+		return t.db
+	}
+
+	return DB
+}
+
+// filterTenant restricts the query to the rows of the tenant of the request
+func filterTenant(c *gin.Context, config *ApiConfig, db *gorm.DB) *gorm.DB {
+	t, err := tenantOf(c, config)
+	if err != nil {
+		db.AddError(err)
+		return db
+	}
+
+	if t == nil || config.Tenancy.column() == "" {
+		return db
+	}
+
+	return db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: config.Tenancy.column()}, Value: t.tenant})
+}
+
+// setTenant sets the tenant of the request on the item, when the model has the column
+func (res *Resource[M]) setTenant(c *gin.Context, item *M) error {
+	t, err := tenantOf(c, res.config)
+	if err != nil || t == nil {
+		return err
+	}
+
+	field, err := res.tenantField()
+	if err != nil || field == nil {
+		return err
+	}
+
+	return field.Set(c, reflect.ValueOf(item), t.tenant)
+}
+
+// checkTenant rejects the input of an update that changes the tenant
+func (res *Resource[M]) checkTenant(c *gin.Context, input *M) error {
+	t, err := tenantOf(c, res.config)
+	if err != nil || t == nil {
+		return err
+	}
+
+	field, err := res.tenantField()
+	if err != nil || field == nil {
+		return err
+	}
+
+	value, zero := field.ValueOf(c, reflect.ValueOf(input))
+	if zero || fmt.Sprint(reflect.Indirect(reflect.ValueOf(value)).Interface()) == fmt.Sprint(t.tenant) {
+		return nil
+	}
+
+	name, _ := jsonName(field.StructField)
+	return validationError(name, "tenant", fmt.Sprintf("%v can't be changed", name))
+}
+
+func (res *Resource[M]) tenantField() (*schema.Field, error) {
+	s, err := res.schema()
+	if err != nil {
+		return nil, err
+	}
+
+	if res.config.Tenancy.column() == "" {
+		return nil, nil
+	}
+
+	return s.LookUpField(res.config.Tenancy.column()), nil
+}
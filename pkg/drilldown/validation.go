@@ -0,0 +1,348 @@
+package drilldown
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	entranslations "github.com/go-playground/validator/v10/translations/en"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	validatorOnce sync.Once
+	translator    ut.Translator
+)
+
+// validate returns the validator used by gin to bind the bodies, set up to report
+// the JSON names of the fields and to translate the messages.
+// It returns false when gin was configured with a different validator
+func validate() (*validator.Validate, bool) {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return nil, false
+	}
+
+	validatorOnce.Do(func() {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name, _ := jsonName(f)
+			return name
+		})
+
+		locale := en.New()
+		translator, _ = ut.New(locale, locale).GetTranslator("en")
+		if err := entranslations.RegisterDefaultTranslations(v, translator); err != nil {
+			translator = nil
+		}
+	})
+
+	return v, true
+}
+
+// RegisterTranslation sets the message of a validation tag, {0} is replaced by the
+// name of the field and {1} by the parameter of the tag, ex: "{0} must be at least {1}"
+func RegisterTranslation(tag string, message string) error {
+	v, ok := validate()
+	if !ok || translator == nil {
+		return nil
+	}
+
+	return v.RegisterTranslation(tag, translator, func(t ut.Translator) error {
+		return t.Add(tag, message, true)
+	}, func(t ut.Translator, fe validator.FieldError) string {
+		msg, err := t.T(fe.Tag(), fe.Field(), fe.Param())
+		if err != nil {
+			return fe.Error()
+		}
+		return msg
+	})
+}
+
+// validationMessage returns the human readable message of the validation error
+func validationMessage(e validator.FieldError) string {
+	if translator == nil {
+		return e.Error()
+	}
+
+	return e.Translate(translator)
+}
+
+// objectBody returns the body of the request, errNotAnObject when it holds
+// another JSON value than an object, null included
+func objectBody(c *gin.Context) ([]byte, error) {
+	body, err := c.GetRawData()
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] != '{' {
+		return nil, errNotAnObject
+	}
+
+	return body, nil
+}
+
+// bindObject decodes and validates the body like ShouldBindJSON, refusing the bodies
+// that are not JSON objects
+func bindObject(c *gin.Context, item interface{}) error {
+	body, err := objectBody(c)
+	if err != nil {
+		return err
+	}
+
+	return binding.JSON.BindBody(body, item)
+}
+
+// bindPartial decodes the body into the item validating only the fields present in it,
+// so the required ones can be left out of partial updates
+func bindPartial(c *gin.Context, item interface{}) error {
+	body, err := objectBody(c)
+	if err != nil {
+		return err
+	}
+
+	var present map[string]json.RawMessage
+	if err := json.Unmarshal(body, &present); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, item); err != nil {
+		return err
+	}
+
+	v, ok := validate()
+	if !ok {
+		return binding.Validator.ValidateStruct(item)
+	}
+
+	fields := presentFields(reflect.TypeOf(item), present)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return v.StructPartial(item, fields...)
+}
+
+// presentFields returns the namespaces of the struct fields set by the JSON keys,
+// as expected by StructPartial, ex: Title or Model.ID for the embedded ones
+func presentFields(t reflect.Type, present map[string]json.RawMessage) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	fields := []string{}
+	for _, f := range modelFields(t) {
+		name, ok := jsonName(f)
+		if _, isPresent := present[name]; !ok || !isPresent {
+			continue
+		}
+
+		sf, ok := t.FieldByName(f.Name)
+		if !ok {
+			continue
+		}
+
+		names := []string{}
+		parent := t
+		for _, i := range sf.Index {
+			field := parent.Field(i)
+			names = append(names, field.Name)
+			parent = field.Type
+		}
+		fields = append(fields, strings.Join(names, "."))
+	}
+
+	return fields
+}
+
+// Validator checks the item about to be written, inside the transaction of the request.
+// It runs on create and update, after the Before hooks, with the item as it will be
+// stored: on updates the fields of the body are applied over the current ones.
+// Return the failures as an HTTPError, ex: NewFieldError, or several of them as Errors,
+// they are reported together with the ones of the other validators
+type Validator func(c *gin.Context, tx *gorm.DB, item interface{}) error
+
+// validatedConfig is the setting of the transaction given to the validators with the config of the resource
+const validatedConfig = "drilldown:validated_config"
+
+func runValidators(c *gin.Context, config *ApiConfig, tx *gorm.DB, item interface{}) error {
+	var errs Errors
+	tx = tx.Set(validatedConfig, config)
+	for _, validator := range config.Validators {
+		err := validator(c, tx, item)
+
+		var fieldErrs Errors
+		var httpErr *HTTPError
+		switch {
+		case err == nil:
+		case errors.As(err, &fieldErrs):
+			errs = append(errs, fieldErrs...)
+		case errors.As(err, &httpErr):
+			errs = append(errs, httpErr)
+		default:
+			return err
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// tenantRows restricts the query of the validators to the rows of the tenant of the request,
+// when the resource has Tenancy and the model has its column
+func tenantRows(c *gin.Context, tx *gorm.DB, s *schema.Schema, q *gorm.DB) *gorm.DB {
+	value, ok := tx.Get(validatedConfig)
+	if !ok {
+		return q
+	}
+
+	config := value.(*ApiConfig)
+	if config.Tenancy == nil || s.LookUpField(config.Tenancy.column()) == nil {
+		return q
+	}
+
+	return filterTenant(c, config, q)
+}
+
+// Unique is a Validator checking that no other row has the same value on the field,
+// given by its JSON name. The scopes restrict the rows compared, as the tenant of
+// the request does when the model has its column. Null values are not checked
+func Unique(field string, scopes ...func(db *gorm.DB) *gorm.DB) Validator {
+	return func(c *gin.Context, tx *gorm.DB, item interface{}) error {
+		s, f, err := lookupJSONField(tx, item, field)
+		if err != nil {
+			return err
+		}
+
+		v := reflect.ValueOf(item)
+		value, zero := f.ValueOf(c, v)
+		if zero {
+			return nil
+		}
+
+		q := tx.Session(&gorm.Session{NewDB: true}).Model(reflect.New(s.ModelType).Interface()).
+			Where(fmt.Sprintf("%v = ?", tx.Statement.Quote(f.DBName)), value)
+		if pk := s.PrioritizedPrimaryField; pk != nil {
+			if id, zero := pk.ValueOf(c, v); !zero {
+				q = q.Where(fmt.Sprintf("%v <> ?", tx.Statement.Quote(pk.DBName)), id)
+			}
+		}
+
+		var count int64
+		if err := tenantRows(c, tx, s, q).Scopes(scopes...).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return validationError(field, "unique", fmt.Sprintf("%v must be unique", field))
+		}
+
+		return nil
+	}
+}
+
+// Exists is a Validator checking that the value of the field, given by its JSON name,
+// is the primary key of a row of R, ex: Exists[Author]("author_id"), of the tenant of the
+// request when R has its column. Null values are not checked
+func Exists[R any](field string) Validator {
+	return func(c *gin.Context, tx *gorm.DB, item interface{}) error {
+		_, f, err := lookupJSONField(tx, item, field)
+		if err != nil {
+			return err
+		}
+
+		value, zero := f.ValueOf(c, reflect.ValueOf(item))
+		if zero {
+			return nil
+		}
+
+		related := &gorm.Statement{DB: tx}
+		if err := related.Parse(new(R)); err != nil {
+			return err
+		}
+		pk := related.Schema.PrioritizedPrimaryField
+		if pk == nil {
+			return fmt.Errorf("%v has no primary key", related.Schema.Name)
+		}
+
+		q := tx.Session(&gorm.Session{NewDB: true}).Model(new(R)).
+			Where(fmt.Sprintf("%v = ?", tx.Statement.Quote(pk.DBName)), value)
+
+		var count int64
+		if err := tenantRows(c, tx, related.Schema, q).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return validationError(field, "exists", fmt.Sprintf("%v does not exist", field))
+		}
+
+		return nil
+	}
+}
+
+// lookupJSONField returns the schema of the item and its field with the given JSON name
+func lookupJSONField(tx *gorm.DB, item interface{}, name string) (*schema.Schema, *schema.Field, error) {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(item); err != nil {
+		return nil, nil, err
+	}
+
+	for _, f := range stmt.Schema.Fields {
+		if n, ok := jsonName(f.StructField); ok && n == name && f.DBName != "" {
+			return stmt.Schema, f, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("invalid field %v on %v", name, stmt.Schema.Name)
+}
+
+// validationError returns the error of a failed validation rule on the field
+func validationError(field string, tag string, message string) *HTTPError {
+	return &HTTPError{
+		Status:  http.StatusBadRequest,
+		Code:    "validation_failed",
+		Message: message,
+		Field:   field,
+		Details: gin.H{"tag": tag},
+	}
+}
+
+// merged returns a copy of the item with the non zero fields of the input applied over it,
+// as they are written by Updates
+func (res *Resource[M]) merged(ctx context.Context, item *M, input *M) (*M, error) {
+	s, err := res.schema()
+	if err != nil {
+		return nil, err
+	}
+
+	candidate := *item
+	to, from := reflect.ValueOf(&candidate), reflect.ValueOf(input)
+	for _, f := range s.Fields {
+		if f.DBName == "" {
+			continue
+		}
+		if value, zero := f.ValueOf(ctx, from); !zero {
+			if err := f.Set(ctx, to, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &candidate, nil
+}
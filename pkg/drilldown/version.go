@@ -0,0 +1,130 @@
+package drilldown
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// versionField returns the integer field of the optimistic locking, the VersionField
+// of the config or the one tagged with `drilldown:"version"`, nil without it
+func (res *Resource[M]) versionField() (*schema.Field, error) {
+	s, err := res.schema()
+	if err != nil {
+		return nil, err
+	}
+
+	var field *schema.Field
+	if res.config.VersionField != "" {
+		if field = s.LookUpField(res.config.VersionField); field == nil {
+			return nil, fmt.Errorf("invalid version field %v on %v", res.config.VersionField, s.Name)
+		}
+	} else {
+		for _, f := range s.Fields {
+			if f.Tag.Get("drilldown") == "version" {
+				field = f
+				break
+			}
+		}
+	}
+
+	if field != nil && field.DataType != schema.Int && field.DataType != schema.Uint {
+		return nil, fmt.Errorf("invalid version field %v on %v, it must be an integer", field.Name, s.Name)
+	}
+
+	return field, nil
+}
+
+// hasVersionTag reports if the model has a field tagged with `drilldown:"version"`
+func hasVersionTag(t reflect.Type) bool {
+	for _, f := range modelFields(t) {
+		if f.Tag.Get("drilldown") == "version" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// versionOf returns the version of the item, 0 when it isn't set
+func versionOf(c *gin.Context, field *schema.Field, item interface{}) int64 {
+	value, zero := field.ValueOf(c, reflect.ValueOf(item))
+	if zero {
+		return 0
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(value))
+	if v.CanUint() {
+		return int64(v.Uint())
+	}
+
+	return v.Int()
+}
+
+// setVersion starts the version of the items created through the API at 1
+func (res *Resource[M]) setVersion(c *gin.Context, item *M) error {
+	field, err := res.versionField()
+	if err != nil || field == nil {
+		return err
+	}
+
+	return field.Set(c, reflect.ValueOf(item), 1)
+}
+
+// lockVersion prepares the update of the item, rejecting the input with a version other
+// than the current one and setting the next one. It returns the current version, which the
+// update statement must match, or -1 without optimistic locking
+func (res *Resource[M]) lockVersion(c *gin.Context, item *M, input *M) (int64, error) {
+	field, err := res.versionField()
+	if err != nil || field == nil {
+		return -1, err
+	}
+
+	current := versionOf(c, field, item)
+	if submitted := versionOf(c, field, input); submitted != 0 && submitted != current {
+		return -1, versionConflict(item)
+	}
+
+	return current, field.Set(c, reflect.ValueOf(input), current+1)
+}
+
+// lockedUpdate updates the item only when it is still on the version read by the request,
+// responding with its current representation when another request changed it
+func (res *Resource[M]) lockedUpdate(c *gin.Context, tx *gorm.DB, item *M, input *M, version int64) error {
+	q := res.mutation(c, tx.Model(item), http.MethodPut, item)
+	if version < 0 {
+		return q.Updates(input).Error
+	}
+
+	field, err := res.versionField()
+	if err != nil {
+		return err
+	}
+
+	result := q.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: version}).Updates(input)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+
+	var current M
+	if err := res.mutation(c, tx.Session(&gorm.Session{NewDB: true}), http.MethodPut, item).First(&current).Error; err != nil {
+		return err
+	}
+
+	return versionConflict(&current)
+}
+
+// versionConflict is the error of the stale updates, with the current item as its details
+func versionConflict(current interface{}) error {
+	return &HTTPError{
+		Status:  http.StatusConflict,
+		Code:    "conflict",
+		Message: "The item has been modified by another request",
+		Details: current,
+	}
+}